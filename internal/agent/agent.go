@@ -0,0 +1,273 @@
+// Package agent lets a model work on a task in a repository by calling local
+// tools. It can read and search the code and its history, propose edits that
+// are staged for review, and run the commands allowed in the config.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/fatih/color"
+)
+
+// maxToolOutput limits how much of a tool's output is sent back to the model
+const maxToolOutput = 40_000
+
+const systemPrompt = `You are an expert software engineer working on a task in a code repository, using tools to explore and change it.
+Look at the relevant code before changing it, and keep changes focused on the task.
+Paths are relative to the repository root.
+
+Propose each change with propose_edit, giving the complete new content of the file. Edits are staged and shown to the user for approval at the end; they are not written to disk, but read_file and run_tests see them.
+When you are done, reply without calling any tools, with a short summary of what you changed and why.`
+
+// Agent works on a task in a repository by letting the model call tools
+type Agent struct {
+	Client   llm.Client
+	Model    string
+	Root     string                // Repository root; the tools can't reach outside it
+	Staging  *fileutil.StagingArea // Receives the proposed edits
+	MaxSteps int                   // Maximum number of model turns
+
+	// Commands lists the commands run_tests may run. run_tests isn't offered
+	// when it is empty.
+	Commands       []Command
+	CommandTimeout time.Duration
+
+	Out io.Writer // Receives the model's messages and the tool calls
+
+	root        string   // Absolute path of Root
+	realRoot    string   // Root with symlinks resolved
+	visible     []string // Files not ignored by git, or nil outside a repository
+	atLineStart bool
+}
+
+// Command is a command the model may run with run_tests
+type Command struct {
+	Command string
+	// AllowArgs lets the model add arguments after Command. Flags such as
+	// "go test -exec=..." can run anything, so this is only as safe as the
+	// command's flags.
+	AllowArgs bool
+}
+
+// tool is a tool offered to the model together with its implementation
+type tool struct {
+	llm.Tool
+	run func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Run works on task until the model stops calling tools, and returns its
+// final message. The proposed edits are left in the staging area.
+func (a *Agent) Run(ctx context.Context, task string) (string, error) {
+	root, err := filepath.Abs(a.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", a.Root, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", a.Root, err)
+	}
+	a.root, a.realRoot = root, realRoot
+	if visible, err := git.VisibleFiles(root); err == nil {
+		a.visible = visible
+	}
+	if a.Out == nil {
+		a.Out = io.Discard
+	}
+	a.atLineStart = true
+
+	tools := a.tools()
+	byName := make(map[string]tool, len(tools))
+	var definitions []llm.Tool
+	for _, t := range tools {
+		byName[t.Name] = t
+		definitions = append(definitions, t.Tool)
+	}
+
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: systemPrompt},
+		{Role: llm.RoleUser, Content: task},
+	}
+	for step := 0; step < a.MaxSteps; step++ {
+		req := llm.ChatRequest{Messages: messages, Tools: definitions, Model: a.Model}
+		reply, err := a.Client.Chat(ctx, req, a.report)
+		if errors.Is(err, llm.ErrOutputTruncated) {
+			return "", fmt.Errorf("%w; ask for a smaller change", err)
+		}
+		if err != nil {
+			return "", err
+		}
+		messages = append(messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			a.endLine()
+			return reply.Content, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			messages = append(messages, llm.ToolResult(call, a.call(ctx, byName, call)))
+		}
+	}
+	a.endLine()
+	return "", fmt.Errorf("stopped after %d steps without finishing; use --max-steps to allow more", a.MaxSteps)
+}
+
+// call runs a tool call and returns its output for the model. Failures are
+// reported to the model, which can often recover from them.
+func (a *Agent) call(ctx context.Context, tools map[string]tool, call llm.ToolCall) string {
+	a.endLine()
+	color.New(color.FgCyan).Fprintf(a.Out, "→ %s %s\n", call.Name, summarizeArgs(call.Arguments))
+
+	t, ok := tools[call.Name]
+	if !ok {
+		return fmt.Sprintf("Error: there is no tool called %s", call.Name)
+	}
+	output, err := t.run(ctx, call.Arguments)
+	if err != nil {
+		color.New(color.FgRed).Fprintf(a.Out, "  %v\n", firstLine(err.Error()))
+		return "Error: " + err.Error()
+	}
+	if len(output) > maxToolOutput {
+		output = output[:maxToolOutput] + "\n... (output truncated)"
+	}
+	return output
+}
+
+// report prints the model's text as it streams in
+func (a *Agent) report(event llm.StreamEvent) {
+	if event.Text == "" {
+		return
+	}
+	fmt.Fprint(a.Out, event.Text)
+	a.atLineStart = strings.HasSuffix(event.Text, "\n")
+}
+
+// endLine ends the model's text with a newline if it didn't have one
+func (a *Agent) endLine() {
+	if !a.atLineStart {
+		fmt.Fprintln(a.Out)
+		a.atLineStart = true
+	}
+}
+
+// resolve returns the absolute path for a path given by the model, which is
+// relative to the repository root. Paths outside the root, including through
+// symlinks, and paths inside .git are refused.
+func (a *Agent) resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(a.root, path)
+	}
+	abs = filepath.Clean(abs)
+	if !within(a.root, abs) {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+
+	// Resolve symlinks in the part of the path that exists
+	existing := abs
+	for {
+		if _, err := os.Lstat(existing); err == nil || existing == a.root {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if !within(a.realRoot, real) {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+
+	for _, part := range strings.Split(a.rel(abs), "/") {
+		if part == ".git" {
+			return "", fmt.Errorf("the .git directory can't be accessed")
+		}
+	}
+	return abs, nil
+}
+
+// rel returns an absolute path inside the root relative to it, for showing to
+// the model
+func (a *Agent) rel(path string) string {
+	rel, err := filepath.Rel(a.root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// stagedPath returns the path a file is staged under: relative to the
+// working directory when it is inside it, like the other commands
+func stagedPath(abs string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, ok := fileutil.RelativePath(wd, abs); ok {
+			return rel
+		}
+	}
+	return abs
+}
+
+// staged returns the staged version of the file at abs, if there is one
+func (a *Agent) staged(abs string) (fileutil.StagedFile, bool) {
+	path := stagedPath(abs)
+	for _, file := range a.Staging.Files {
+		if file.OriginalPath == path {
+			return file, true
+		}
+	}
+	return fileutil.StagedFile{}, false
+}
+
+// within reports whether path is root or inside it
+func within(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// summarizeArgs returns a tool call's arguments on one line for progress
+// output, leaving out long values such as file content
+func summarizeArgs(arguments json.RawMessage) string {
+	var args map[string]any
+	if err := json.Unmarshal(arguments, &args); err != nil || len(args) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		value := fmt.Sprint(args[key])
+		if text, ok := args[key].(string); ok {
+			value = fmt.Sprintf("%q", text)
+			if len(text) > 60 || strings.Contains(text, "\n") {
+				value = fmt.Sprintf("(%d bytes)", len(text))
+			}
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
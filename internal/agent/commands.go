@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/verify"
+)
+
+// runTests implements run_tests. The command must be one of the allowed
+// commands, with extra arguments only where they are allowed, and is run
+// without a shell, in a temporary copy of the repository with the staged
+// edits applied, so it can't change the working tree.
+func (a *Agent) runTests(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	fields, err := a.allowedCommand(args.Command)
+	if err != nil {
+		return "", err
+	}
+
+	files := make(map[string]string)
+	var deleted []string
+	for _, file := range a.Staging.Files {
+		if file.IsDelete {
+			deleted = append(deleted, file.OriginalPath)
+			continue
+		}
+		files[file.OriginalPath] = file.Content
+	}
+
+	// Quote every argument so that the shell used by RunInCopy runs the
+	// command as given
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = shellQuote(field)
+	}
+	result, err := verify.RunInCopy(ctx, a.root, a.root, files, deleted, strings.Join(quoted, " "), a.CommandTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	status := "Command succeeded"
+	if !result.Passed {
+		status = "Command failed"
+	}
+	return fmt.Sprintf("%s: %s\n%s", status, args.Command, verify.Summarize(result.Output, 300)), nil
+}
+
+// allowedCommand splits command into its arguments and checks that it is one
+// of the allowed commands, or starts with one that allows extra arguments,
+// and that no argument is a path outside the repository
+func (a *Agent) allowedCommand(command string) ([]string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	allowed := false
+	for _, candidate := range a.Commands {
+		prefix := strings.Fields(candidate.Command)
+		if len(prefix) == 0 || len(prefix) > len(fields) || !equalFields(prefix, fields[:len(prefix)]) {
+			continue
+		}
+		if len(prefix) == len(fields) || candidate.AllowArgs {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%q is not an allowed command; allowed commands are: %s", command, a.describeCommands())
+	}
+
+	for _, field := range fields[1:] {
+		// Check anything that looks like a path, including flag values such
+		// as -coverprofile=/tmp/out
+		value := field
+		if _, after, ok := strings.Cut(field, "="); ok && strings.HasPrefix(field, "-") {
+			value = after
+		}
+		if !filepath.IsAbs(value) && !strings.Contains(value, "..") {
+			continue
+		}
+		path := value
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(a.root, path)
+		}
+		if !within(a.root, filepath.Clean(path)) {
+			return nil, fmt.Errorf("argument %s is outside the repository", field)
+		}
+	}
+	return fields, nil
+}
+
+// describeCommands lists the allowed commands for the model, marking those
+// that accept extra arguments
+func (a *Agent) describeCommands() string {
+	descriptions := make([]string, len(a.Commands))
+	for i, command := range a.Commands {
+		descriptions[i] = command.Command
+		if command.AllowArgs {
+			descriptions[i] += " [arguments]"
+		}
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// equalFields reports whether a and b hold the same strings
+func equalFields(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,397 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/EricBriscoe/llm-tool/internal/verify"
+)
+
+// maxGrepMatches limits the number of lines grep returns
+const maxGrepMatches = 200
+
+// tools returns the tools offered to the model
+func (a *Agent) tools() []tool {
+	tools := []tool{
+		{
+			Tool: newTool("read_file", "Read a text file, or a range of its lines. Shows the staged version if you proposed an edit to it.", `{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path of the file"},
+					"start_line": {"type": "integer", "description": "First line to read, from 1"},
+					"end_line": {"type": "integer", "description": "Last line to read"}
+				},
+				"required": ["path"]
+			}`),
+			run: a.readFile,
+		},
+		{
+			Tool: newTool("list_dir", "List the files and directories in a directory, leaving out files ignored by git. Directories end with /.", `{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path of the directory, . for the repository root"}
+				},
+				"required": ["path"]
+			}`),
+			run: a.listDir,
+		},
+		{
+			Tool: newTool("grep", "Search the text files under a path for lines matching a regular expression (RE2 syntax). Returns path:line: text for each match.", `{
+				"type": "object",
+				"properties": {
+					"pattern": {"type": "string", "description": "Regular expression to search for"},
+					"path": {"type": "string", "description": "File or directory to search, . for the whole repository"},
+					"include": {"type": "string", "description": "Only search files matching this glob, e.g. *.go"}
+				},
+				"required": ["pattern"]
+			}`),
+			run: a.grep,
+		},
+		{
+			Tool: newTool("git_log", "Show the most recent commits, or those that changed a path.", `{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Only show commits that changed this path"},
+					"max_count": {"type": "integer", "description": "Number of commits to show, default 10"}
+				}
+			}`),
+			run: a.gitLog,
+		},
+		{
+			Tool: newTool("git_blame", "Show who last changed each line of a file, and in which commit.", `{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path of the file"},
+					"start_line": {"type": "integer", "description": "First line to show"},
+					"end_line": {"type": "integer", "description": "Last line to show"}
+				},
+				"required": ["path"]
+			}`),
+			run: a.gitBlame,
+		},
+		{
+			Tool: newTool("propose_edit", "Propose the complete new content of a file, creating it if needed, or propose deleting it. The change is staged for the user's approval.", `{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path of the file"},
+					"content": {"type": "string", "description": "Complete new content of the file"},
+					"delete": {"type": "boolean", "description": "Delete the file instead"}
+				},
+				"required": ["path"]
+			}`),
+			run: a.proposeEdit,
+		},
+	}
+
+	if len(a.Commands) > 0 {
+		description := fmt.Sprintf("Run a command in a copy of the repository with your proposed edits applied, and return its output. Only these commands are allowed, exactly as written; those marked [arguments] may be followed by more arguments: %s. Arguments are split on spaces and not interpreted by a shell.", a.describeCommands())
+		tools = append(tools, tool{
+			Tool: newTool("run_tests", description, `{
+				"type": "object",
+				"properties": {
+					"command": {"type": "string", "description": "Command to run"}
+				},
+				"required": ["command"]
+			}`),
+			run: a.runTests,
+		})
+	}
+	return tools
+}
+
+// readFile implements read_file
+func (a *Agent) readFile(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	abs, err := a.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var content string
+	note := ""
+	if file, ok := a.staged(abs); ok {
+		if file.IsDelete {
+			return "", fmt.Errorf("%s is staged for deletion", args.Path)
+		}
+		content = file.Content
+		note = ", with your proposed edit"
+	} else {
+		if binary, err := fileutil.IsBinaryFile(abs); err != nil {
+			return "", err
+		} else if binary {
+			return "", fmt.Errorf("%s is a binary file", args.Path)
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return "", err
+		}
+		content = string(data)
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start, end := max(args.StartLine, 1), len(lines)
+	if args.EndLine > 0 && args.EndLine < end {
+		end = args.EndLine
+	}
+	if start > end {
+		return fmt.Sprintf("%s has %d lines", a.rel(abs), len(lines)), nil
+	}
+
+	body := strings.Join(lines[start-1:end], "")
+	if len(body) > maxToolOutput {
+		// Cut at a line boundary and say where to continue
+		cut := strings.LastIndex(body[:maxToolOutput], "\n") + 1
+		end = start + strings.Count(body[:cut], "\n") - 1
+		body = body[:cut]
+	}
+	header := fmt.Sprintf("%s, lines %d-%d of %d%s:\n", a.rel(abs), start, end, len(lines), note)
+	return header + body, nil
+}
+
+// listDir implements list_dir
+func (a *Agent) listDir(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	abs, err := a.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+
+	// Hide what git ignores, such as build output and dependencies
+	var visible map[string]bool
+	if a.visible != nil {
+		visible = make(map[string]bool)
+		for _, path := range a.visible {
+			for ; within(abs, path) && path != abs; path = filepath.Dir(path) {
+				visible[path] = true
+			}
+		}
+	}
+
+	var names []string
+	for _, entry := range entries {
+		path := filepath.Join(abs, entry.Name())
+		if entry.Name() == ".git" || (visible != nil && !visible[path]) {
+			continue
+		}
+		if entry.IsDir() {
+			names = append(names, entry.Name()+"/")
+		} else {
+			names = append(names, entry.Name())
+		}
+	}
+	// Include files proposed in this directory
+	for _, file := range a.Staging.Files {
+		path, err := filepath.Abs(file.OriginalPath)
+		if err == nil && file.IsNew && filepath.Dir(path) == abs {
+			names = append(names, filepath.Base(path)+" (proposed)")
+		}
+	}
+
+	if len(names) == 0 {
+		return a.rel(abs) + " is empty", nil
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n"), nil
+}
+
+// grep implements grep
+func (a *Agent) grep(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+		Include string `json:"include"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	abs, err := a.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	opts := fileutil.TargetOptions{Visible: a.visible}
+	if args.Include != "" {
+		opts.Include = []string{args.Include}
+	}
+	files, _, err := fileutil.ExpandTargets([]string{abs}, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	matches := 0
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if matches == maxGrepMatches {
+				fmt.Fprintf(&sb, "... stopped after %d matches; narrow the pattern or path\n", maxGrepMatches)
+				return sb.String(), nil
+			}
+			fmt.Fprintf(&sb, "%s:%d: %s\n", a.rel(path), i+1, strings.TrimRight(line, "\r"))
+			matches++
+		}
+	}
+	if matches == 0 {
+		return "No matches", nil
+	}
+	return sb.String(), nil
+}
+
+// gitLog implements git_log
+func (a *Agent) gitLog(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path     string `json:"path"`
+		MaxCount int    `json:"max_count"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path := ""
+	if args.Path != "" {
+		abs, err := a.resolve(args.Path)
+		if err != nil {
+			return "", err
+		}
+		path = abs
+	}
+	limit := args.MaxCount
+	if limit < 1 {
+		limit = 10
+	}
+
+	entries, err := git.FileLog(a.root, path, min(limit, 50))
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "No commits", nil
+	}
+	return git.FormatLog(entries), nil
+}
+
+// gitBlame implements git_blame
+func (a *Agent) gitBlame(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	abs, err := a.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	return git.Blame(a.root, abs, args.StartLine, args.EndLine)
+}
+
+// proposeEdit implements propose_edit, staging the change for review
+func (a *Agent) proposeEdit(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path    string  `json:"path"`
+		Content *string `json:"content"`
+		Delete  bool    `json:"delete"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	abs, err := a.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	path := stagedPath(abs)
+	info, statErr := os.Stat(abs)
+	exists := statErr == nil
+	if exists && info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", args.Path)
+	}
+
+	if args.Delete {
+		if !exists {
+			a.Staging.Unstage(path)
+			return fmt.Sprintf("Dropped the proposed new file %s", a.rel(abs)), nil
+		}
+		if err := a.Staging.StageDeletion(path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Staged the deletion of %s", a.rel(abs)), nil
+	}
+	if args.Content == nil {
+		return "", fmt.Errorf("give the file's content, or delete: true")
+	}
+
+	content := *args.Content
+	if filepath.Ext(abs) == ".go" {
+		formatted, err := verify.FormatGo(a.rel(abs), content)
+		if err != nil {
+			return "", fmt.Errorf("not staged: %w", err)
+		}
+		content = formatted
+	}
+
+	_, wasStaged := a.staged(abs)
+	if _, err := a.Staging.StageFile(path, content, !exists); err != nil {
+		return "", err
+	}
+	if exists && !wasStaged {
+		// Detect changes made to the file while the agent works
+		original, err := os.ReadFile(abs)
+		if err != nil {
+			return "", err
+		}
+		a.Staging.SetOriginal(path, string(original))
+	}
+
+	if !exists {
+		return fmt.Sprintf("Staged the new file %s", a.rel(abs)), nil
+	}
+	return fmt.Sprintf("Staged the edit to %s", a.rel(abs)), nil
+}
+
+// newTool returns a tool definition with a JSON schema for its arguments
+func newTool(name string, description string, parameters string) llm.Tool {
+	return llm.Tool{Name: name, Description: description, Parameters: json.RawMessage(parameters)}
+}
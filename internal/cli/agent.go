@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/EricBriscoe/llm-tool/internal/agent"
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// newAgentCmd creates the command that lets the model work on a task with tools
+func newAgentCmd() *cobra.Command {
+	var provider string
+	var model string
+	var maxSteps int
+	var noCommands bool
+	var applyChanges bool
+
+	agentCmd := &cobra.Command{
+		Use:   "agent [task]",
+		Short: "Let the model explore the repository and propose edits for a task",
+		Long: `Work on a task by letting the model call local tools. It can read files,
+list directories, grep, and look at git log and blame, all confined to the
+repository root. The edits it proposes are staged, shown as a diff and applied
+only after confirmation.
+
+The model can't run commands unless they are listed under agent.commands in
+the config file, for example:
+
+  agent:
+    commands:
+      - go test ./...
+      - go vet ./...
+      - command: ./scripts/test.sh
+        allowArgs: true
+    commandTimeout: 120
+
+Commands run without a shell, in a temporary copy of the repository with the
+proposed edits applied, but are otherwise not sandboxed. A listed command must
+be run exactly as written unless it has allowArgs, which lets the model add any
+arguments after it. Only use allowArgs for commands whose flags can't run other
+programs or write outside the copy: "go test" with extra arguments, for
+example, accepts -exec and -toolexec, and so can run anything.`,
+		Example: `  llm-tool agent "Find why TestParse is flaky and fix it"`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			task := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if provider == "" {
+				provider = cfg.DefaultProvider
+			}
+			if !cmd.Flags().Changed("max-steps") {
+				maxSteps = cfg.Agent.MaxSteps
+			}
+			if maxSteps < 1 {
+				return fmt.Errorf("--max-steps must be at least 1")
+			}
+
+			client, err := llm.NewClient(provider, cfg)
+			if err != nil {
+				return err
+			}
+
+			stagingArea, err := fileutil.NewStagingArea()
+			if err != nil {
+				return err
+			}
+			defer stagingArea.Cleanup()
+			// Outside a git repository, the agent works in the current directory
+			root := "."
+			if repoRoot, err := git.RepoRoot(""); err == nil {
+				root = repoRoot
+				stagingArea.BaseDir = repoRoot
+			}
+			stagingArea.HistoryDir = config.GetHistoryDir()
+			stagingArea.Description = task
+
+			a := &agent.Agent{
+				Client:         client,
+				Model:          model,
+				Root:           root,
+				Staging:        stagingArea,
+				MaxSteps:       maxSteps,
+				CommandTimeout: time.Duration(cfg.Agent.CommandTimeout) * time.Second,
+				Out:            os.Stdout,
+			}
+			if !noCommands {
+				for _, command := range cfg.Agent.Commands {
+					a.Commands = append(a.Commands, agent.Command{Command: command.Command, AllowArgs: command.AllowArgs})
+				}
+			}
+
+			_, err = a.Run(cmd.Context(), task)
+			if errors.Is(err, llm.ErrToolsNotSupported) {
+				return fmt.Errorf("the %s provider can't call tools; use openai or gemini", provider)
+			}
+			if err != nil {
+				if len(stagingArea.Files) == 0 {
+					return err
+				}
+				// Let the edits proposed so far be reviewed
+				color.New(color.FgRed).Printf("\nThe agent stopped: %v\n", err)
+			}
+
+			if len(stagingArea.Files) == 0 {
+				fmt.Println("\nNo edits were proposed.")
+				return nil
+			}
+			return reviewAndApply(cmd.Context(), stagingArea, applyChanges)
+		},
+	}
+
+	agentCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, gemini)")
+	agentCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	agentCmd.Flags().IntVar(&maxSteps, "max-steps", 30, "Maximum number of model turns (defaults to agent.maxSteps in config)")
+	agentCmd.Flags().BoolVar(&noCommands, "no-commands", false, "Don't let the model run the commands allowed in the config")
+	agentCmd.Flags().BoolVarP(&applyChanges, "yes", "y", false, "Apply changes without confirmation")
+	return agentCmd
+}
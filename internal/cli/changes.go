@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// newPRDescriptionCmd creates the command that writes pull request descriptions
+func newPRDescriptionCmd() *cobra.Command {
+	var provider string
+	var model string
+	var repoPath string
+	var templateFile string
+	var outputFile string
+
+	prCmd := &cobra.Command{
+		Use:   "pr-description [base]",
+		Short: "Generate a pull request title and description",
+		Long: `Generate a pull request title and Markdown description for the commits on
+the current branch that are not on base.
+
+The body follows .llm-tool/pr-template.md or .github/pull_request_template.md
+if either exists, or the file given with --template.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base := args[0]
+
+			entries, err := git.Log(base+"..HEAD", repoPath)
+			if err != nil {
+				return fmt.Errorf("failed to read commit log: %w", err)
+			}
+			if len(entries) == 0 {
+				return fmt.Errorf("no commits found between %s and HEAD", base)
+			}
+
+			diff, err := git.GetRangeDiff(base+"...HEAD", repoPath)
+			if err != nil {
+				return fmt.Errorf("failed to get diff: %w", err)
+			}
+
+			template, err := loadTemplate(repoPath, templateFile, "pr-template.md", filepath.Join(".github", "pull_request_template.md"))
+			if err != nil {
+				return err
+			}
+
+			client, err := newClientFromConfig(provider)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Describing %d commits...\n", len(entries))
+			description, err := llm.GeneratePRDescription(cmd.Context(), client, git.FormatLog(entries), diff, template, model)
+			if err != nil {
+				return fmt.Errorf("failed to generate description: %w", err)
+			}
+
+			return writeOutput(outputFile, description)
+		},
+	}
+
+	prCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
+	prCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	prCmd.Flags().StringVarP(&repoPath, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	prCmd.Flags().StringVarP(&templateFile, "template", "t", "", "Template for the description body")
+	prCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the description to a file instead of stdout")
+
+	return prCmd
+}
+
+// newChangelogCmd creates the command that writes changelogs for a commit range
+func newChangelogCmd() *cobra.Command {
+	var provider string
+	var model string
+	var repoPath string
+	var templateFile string
+	var outputFile string
+	var title string
+
+	changelogCmd := &cobra.Command{
+		Use:   "changelog [from..to]",
+		Short: "Generate a Markdown changelog for a range of commits",
+		Long: `Generate a Markdown changelog for a range of commits, grouped into breaking
+changes, features, fixes and other changes. A range without ".." runs up to HEAD.
+
+The layout follows .llm-tool/changelog-template.md if it exists, or the file
+given with --template.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revRange := args[0]
+			if !strings.Contains(revRange, "..") {
+				revRange += "..HEAD"
+			}
+
+			entries, err := git.Log(revRange, repoPath)
+			if err != nil {
+				return fmt.Errorf("failed to read commit log: %w", err)
+			}
+			if len(entries) == 0 {
+				return fmt.Errorf("no commits found in %s", revRange)
+			}
+
+			diff, err := git.GetRangeDiff(revRange, repoPath)
+			if err != nil {
+				return fmt.Errorf("failed to get diff: %w", err)
+			}
+
+			template, err := loadTemplate(repoPath, templateFile, "changelog-template.md")
+			if err != nil {
+				return err
+			}
+
+			if title == "" {
+				// Name the entry after the end of the range, e.g. "v1.2.0"
+				title = rangeEnd(revRange)
+			}
+
+			client, err := newClientFromConfig(provider)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Summarizing %d commits...\n", len(entries))
+			changelog, err := llm.GenerateChangelog(cmd.Context(), client, title, git.FormatLog(entries), diff, template, model)
+			if err != nil {
+				return fmt.Errorf("failed to generate changelog: %w", err)
+			}
+
+			return writeOutput(outputFile, changelog)
+		},
+	}
+
+	changelogCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
+	changelogCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	changelogCmd.Flags().StringVarP(&repoPath, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	changelogCmd.Flags().StringVarP(&templateFile, "template", "t", "", "Template for the changelog layout")
+	changelogCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the changelog to a file instead of stdout")
+	changelogCmd.Flags().StringVar(&title, "title", "", "Heading for the changelog entry (defaults to the end of the range)")
+
+	return changelogCmd
+}
+
+// newClientFromConfig loads the config and creates a client for provider,
+// falling back to the configured default provider
+func newClientFromConfig(provider string) (llm.Client, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if provider == "" {
+		provider = cfg.DefaultProvider
+	}
+
+	return llm.NewClient(provider, cfg)
+}
+
+// loadTemplate returns the contents of explicitPath if set. Otherwise it looks
+// for projectName in the .llm-tool directory and then for each fallback path
+// relative to the repository root, returning an empty string if none exist.
+func loadTemplate(repoPath string, explicitPath string, projectName string, fallbacks ...string) (string, error) {
+	if explicitPath != "" {
+		data, err := os.ReadFile(explicitPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read template: %w", err)
+		}
+		return string(data), nil
+	}
+
+	template, err := config.LoadProjectFile(repoPath, projectName)
+	if err != nil || template != "" {
+		return template, err
+	}
+
+	if len(fallbacks) == 0 {
+		return "", nil
+	}
+
+	root, err := git.RepoRoot(repoPath)
+	if err != nil {
+		return "", nil
+	}
+	for _, fallback := range fallbacks {
+		if data, err := os.ReadFile(filepath.Join(root, fallback)); err == nil {
+			return string(data), nil
+		}
+	}
+	return "", nil
+}
+
+// writeOutput writes content to path, or to stdout if path is empty
+func writeOutput(path string, content string) error {
+	if path == "" {
+		fmt.Print(content)
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
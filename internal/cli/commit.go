@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// newCommitCmd creates the command that writes commit messages for staged changes
+func newCommitCmd() *cobra.Command {
+	var provider string
+	var model string
+	var repoPath string
+	var style string
+	var maxSubject int
+	var bodyWrap int
+	var acceptMessage bool
+	var dryRun bool
+	var installHook bool
+	var hookFile string
+
+	// run generates the message and commits, or fills in hookFile when run
+	// from the prepare-commit-msg hook
+	run := func(cmd *cobra.Command) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if provider == "" {
+			provider = cfg.DefaultProvider
+		}
+
+		opts := llm.CommitOptions{
+			Style:            cfg.Commit.Style,
+			MaxSubjectLength: cfg.Commit.MaxSubjectLength,
+			BodyWrap:         cfg.Commit.BodyWrap,
+		}
+		if style != "" {
+			opts.Style = style
+		}
+		if maxSubject > 0 {
+			opts.MaxSubjectLength = maxSubject
+		}
+		if bodyWrap > 0 {
+			opts.BodyWrap = bodyWrap
+		}
+
+		diff, err := git.GetStagedDiff(repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get staged diff: %w", err)
+		}
+		if strings.TrimSpace(diff) == "" {
+			if hookFile != "" {
+				return nil
+			}
+			return fmt.Errorf("no staged changes to commit")
+		}
+
+		stat, err := git.GetStagedStat(repoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get staged files: %w", err)
+		}
+
+		client, err := llm.NewClient(provider, cfg)
+		if err != nil {
+			return err
+		}
+
+		generate := func() (string, error) {
+			return llm.GenerateCommitMessage(cmd.Context(), client, diff, stat, opts, model)
+		}
+
+		if hookFile != "" {
+			message, err := generate()
+			if err != nil {
+				return err
+			}
+			return prependToFile(hookFile, message)
+		}
+
+		fmt.Fprintln(os.Stderr, "Generating commit message...")
+		message, err := generate()
+		if err != nil {
+			return fmt.Errorf("failed to generate commit message: %w", err)
+		}
+
+		if dryRun {
+			fmt.Print(message)
+			return nil
+		}
+
+		if !acceptMessage {
+			message, err = confirmCommitMessage(message, opts, generate)
+			if err != nil || message == "" {
+				return err
+			}
+		}
+
+		if len(strings.SplitN(message, "\n", 2)[0]) > opts.MaxSubjectLength {
+			fmt.Fprintf(os.Stderr, "Warning: subject line is longer than %d characters\n", opts.MaxSubjectLength)
+		}
+
+		messageFile, err := writeTempMessage(message)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(messageFile)
+
+		return git.Commit(repoPath, messageFile)
+	}
+
+	commitCmd := &cobra.Command{
+		Use:   "commit",
+		Short: "Generate a commit message for staged changes and commit",
+		Long: `Generate a commit message from the staged diff, let you accept, edit or
+regenerate it, and then run git commit with the result.
+
+With --install-hook, install a prepare-commit-msg hook that pre-fills the
+message whenever you run a plain "git commit".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if installHook {
+				return installCommitHook(repoPath)
+			}
+
+			if hookFile != "" {
+				// Never block the commit from a hook; git will open the editor anyway
+				if err := run(cmd); err != nil {
+					fmt.Fprintf(os.Stderr, "llm-tool: could not generate commit message: %v\n", err)
+				}
+				return nil
+			}
+			return run(cmd)
+		},
+	}
+
+	commitCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
+	commitCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	commitCmd.Flags().StringVarP(&repoPath, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	commitCmd.Flags().StringVar(&style, "style", "", "Message style: conventional or plain (defaults to config)")
+	commitCmd.Flags().IntVar(&maxSubject, "max-subject", 0, "Maximum subject line length (defaults to config)")
+	commitCmd.Flags().IntVar(&bodyWrap, "wrap", 0, "Column to wrap the message body at (defaults to config)")
+	commitCmd.Flags().BoolVarP(&acceptMessage, "yes", "y", false, "Commit with the generated message without confirmation")
+	commitCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the generated message without committing")
+	commitCmd.Flags().BoolVar(&installHook, "install-hook", false, "Install a prepare-commit-msg hook instead of committing")
+	commitCmd.Flags().StringVar(&hookFile, "hook-file", "", "Write the message into this file (used by the prepare-commit-msg hook)")
+	commitCmd.Flags().MarkHidden("hook-file")
+
+	return commitCmd
+}
+
+// confirmCommitMessage lets the user accept, edit or regenerate a message.
+// It returns an empty message if the user aborts.
+func confirmCommitMessage(message string, opts llm.CommitOptions, generate func() (string, error)) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\n%s\n", message)
+		fmt.Print("[a]ccept, [e]dit, [r]egenerate or [q]uit? ")
+
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(response)) {
+		case "a", "y", "":
+			return message, nil
+		case "e":
+			edited, err := editCommitMessage(message, opts.BodyWrap)
+			if err != nil {
+				return "", err
+			}
+			if edited == "" {
+				fmt.Println("Empty message, not committing.")
+				return "", nil
+			}
+			message = edited
+		case "r":
+			fmt.Fprintln(os.Stderr, "Regenerating commit message...")
+			regenerated, err := generate()
+			if err != nil {
+				return "", fmt.Errorf("failed to generate commit message: %w", err)
+			}
+			message = regenerated
+		case "q", "n":
+			fmt.Println("Commit aborted.")
+			return "", nil
+		}
+	}
+}
+
+// editCommitMessage opens the message in the user's editor and returns the
+// result with comment lines removed
+func editCommitMessage(message string, width int) (string, error) {
+	path, err := writeTempMessage(message + "\n# Lines starting with '#' are ignored. An empty message aborts the commit.\n")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	if err := openInEditor(path); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited message: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return llm.FormatCommitMessage(strings.Join(lines, "\n"), width), nil
+}
+
+// writeTempMessage writes a commit message to a temporary file and returns its path
+func writeTempMessage(message string) (string, error) {
+	f, err := os.CreateTemp("", "llm-tool-commit-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create message file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(message); err != nil {
+		return "", fmt.Errorf("failed to write message file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// prependToFile writes message at the top of path, keeping the existing
+// content (git's commented status summary) below it
+func prependToFile(path string, message string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, []byte(message+string(existing)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// installCommitHook installs a prepare-commit-msg hook that runs this binary
+func installCommitHook(repoPath string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate llm-tool executable: %w", err)
+	}
+
+	// Only pre-fill plain "git commit" invocations; messages from -m,
+	// templates, merges and amends are left alone
+	body := fmt.Sprintf(`case "$2" in
+"") ;;
+*) exit 0 ;;
+esac
+exec %q commit --hook-file "$1"`, executable)
+
+	hookPath, err := git.InstallHook(repoPath, "prepare-commit-msg", body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed prepare-commit-msg hook at %s\n", hookPath)
+	return nil
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/fatih/color"
+)
+
+// resolveConflicts handles staged files whose originals changed on disk while
+// the model was working. With assumeYes, clean three-way merges are taken and
+// any other conflict aborts; otherwise the user chooses to merge, overwrite or
+// skip each file. It reports whether the apply should go ahead.
+func resolveConflicts(stagingArea *fileutil.StagingArea, assumeYes bool) (bool, error) {
+	conflicts, err := stagingArea.Conflicts()
+	if err != nil || len(conflicts) == 0 {
+		return err == nil, err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	yellow := color.New(color.FgYellow)
+
+	for _, conflict := range conflicts {
+		path := conflict.File.OriginalPath
+		if conflict.Deleted {
+			yellow.Printf("\n%s was deleted since it was edited.\n", path)
+			if assumeYes {
+				return false, fmt.Errorf("not applying changes: %s was deleted since it was edited", path)
+			}
+
+			response, err := prompt(reader, "[o]verwrite (recreate it), [s]kip or [q]uit? ")
+			if err != nil {
+				return false, err
+			}
+			switch strings.ToLower(response) {
+			case "o":
+				if err := stagingArea.ResolveConflict(path, conflict.File.Content); err != nil {
+					return false, err
+				}
+			case "s":
+				stagingArea.Unstage(path)
+			default:
+				return false, nil
+			}
+			continue
+		}
+
+		if conflict.File.IsDelete {
+			yellow.Printf("\n%s changed on disk since it was staged for deletion.\n", path)
+			if assumeYes {
+				return false, fmt.Errorf("not applying changes: %s changed on disk since it was staged for deletion", path)
+			}
+
+			response, err := prompt(reader, "[d]elete it anyway, [s]kip it or [q]uit? ")
+			if err != nil {
+				return false, err
+			}
+			switch strings.ToLower(response) {
+			case "d":
+				if err := stagingArea.StageDeletion(path); err != nil {
+					return false, err
+				}
+			case "s":
+				stagingArea.Unstage(path)
+			default:
+				return false, nil
+			}
+			continue
+		}
+
+		yellow.Printf("\n%s changed on disk since it was edited.\n", path)
+		merged, clean, err := git.MergeFile(conflict.Current, conflict.File.OriginalContent, conflict.File.Content, "llm-tool")
+		if err != nil {
+			return false, err
+		}
+
+		if assumeYes {
+			if !clean {
+				return false, fmt.Errorf("not applying changes: %s changed on disk and the edit doesn't merge cleanly", path)
+			}
+			fmt.Printf("Merged the edit with the changes on disk.\n")
+			if err := stagingArea.ResolveConflict(path, merged); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		for resolved := false; !resolved; {
+			question := "[m]erge with the changes on disk, [o]verwrite them, [s]kip this file or [q]uit? "
+			if !clean {
+				question = "The edit conflicts with the changes on disk. [m]erge and resolve in $EDITOR, [o]verwrite them, [s]kip this file or [q]uit? "
+			}
+			response, err := prompt(reader, question)
+			if err != nil {
+				return false, err
+			}
+
+			switch strings.ToLower(response) {
+			case "m":
+				content := merged
+				if !clean {
+					content, err = resolveInEditor(path, merged)
+					if err != nil {
+						return false, err
+					}
+					if hasConflictMarkers(content) {
+						yellow.Println("The file still contains conflict markers.")
+						merged = content
+						continue
+					}
+				}
+				if err := stagingArea.ResolveConflict(path, content); err != nil {
+					return false, err
+				}
+				resolved = true
+			case "o":
+				if err := stagingArea.ResolveConflict(path, conflict.File.Content); err != nil {
+					return false, err
+				}
+				resolved = true
+			case "s":
+				stagingArea.Unstage(path)
+				resolved = true
+			case "q":
+				return false, nil
+			}
+		}
+	}
+
+	return len(stagingArea.Files) > 0, nil
+}
+
+// resolveInEditor opens merged text containing conflict markers in the user's
+// editor and returns the saved result
+func resolveInEditor(path string, merged string) (string, error) {
+	tmp, err := os.CreateTemp("", "llm-tool-merge-*-"+sanitizeFilename(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(merged); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	tmp.Close()
+
+	if err := openInEditor(tmp.Name()); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read resolved file: %w", err)
+	}
+	return string(content), nil
+}
+
+// hasConflictMarkers reports whether text still contains merge conflict markers
+func hasConflictMarkers(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") || line == "=======" {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename returns the base name of path for use in a temporary file
+// pattern, so that editors can pick a syntax mode from its extension
+func sanitizeFilename(path string) string {
+	return strings.ReplaceAll(filepath.Base(path), "*", "_")
+}
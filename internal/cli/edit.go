@@ -0,0 +1,829 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/EricBriscoe/llm-tool/internal/verify"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// Edit modes accepted by --mode, in addition to llm.EditModeSearchReplace and llm.EditModeUnifiedDiff
+const (
+	editModeWhole = "whole"
+	editModeAuto  = "auto"
+)
+
+// autoPatchLines is the file size at which --mode auto switches from whole-file rewrites to search/replace edits
+const autoPatchLines = 300
+
+// editOptions holds the settings shared by every file processed by edit
+type editOptions struct {
+	instructions string
+	mode         string
+	model        string
+	onTruncate   string // "continue" or "fail"
+	allowShrink  bool
+	// strictBrackets fails edits to files other than Go whose brackets no
+	// longer balance, instead of warning about them
+	strictBrackets bool
+
+	// notef prints progress notes; it defaults to fmt.Printf
+	notef func(format string, args ...any)
+}
+
+// note prints a progress note about a file
+func (o editOptions) note(format string, args ...any) {
+	if o.notef != nil {
+		o.notef(format, args...)
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// lostContentChecks returns the checks llm.DetectLostContent should apply
+func (o editOptions) lostContentChecks() llm.LostContentChecks {
+	return llm.LostContentChecks{Shrink: !o.allowShrink, StrictBrackets: o.strictBrackets}
+}
+
+// editResult is the outcome of editing one file
+type editResult struct {
+	filename string
+	original string // Content sent to the model
+	content  string
+	deleted  bool // The model deleted the file
+	err      error
+}
+
+// editFiles edits files with up to jobs requests in flight, reporting progress
+// on stderr. A failure doesn't stop the other files; results are returned in
+// the order of files.
+func editFiles(ctx context.Context, client llm.Client, files []string, opts editOptions, jobs int) []editResult {
+	results := make([]editResult, len(files))
+	p := newProgress(len(files))
+	defer p.stop()
+	opts.notef = p.notef
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(jobs, len(files)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				filename := files[i]
+				p.start(filename)
+
+				result := editResult{filename: filename}
+				content, err := fileutil.ReadFileContent(filename)
+				if err == nil {
+					result.original = content
+					result.content, err = editContent(ctx, client, filename, content, opts)
+				}
+				result.err = err
+				results[i] = result
+
+				p.finish(filename, err)
+			}
+		}()
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// showEditFailures lists the files that could not be edited
+func showEditFailures(failed []editResult) {
+	red := color.New(color.FgRed)
+	red.Printf("\n%d files could not be edited and were not staged:\n", len(failed))
+	for _, result := range failed {
+		red.Printf("  ✗ %s: %s\n", result.filename, strings.ReplaceAll(result.err.Error(), "\n", "\n    "))
+	}
+}
+
+// newEditCmd creates the command that edits files with an LLM
+func newEditCmd() *cobra.Command {
+	var provider string
+	var model string
+	var datasource string
+	var applyChanges bool
+	var outputDir string
+	var mode string
+	var onTruncate string
+	var allowShrink bool
+	var strictBrackets bool
+	var typecheck bool
+	var verifyCommand string
+	var fixUntilGreen bool
+	var maxIterations int
+	var tokenBudget int
+	var selection targetSelection
+	var jobs int
+	var interactive bool
+	var stageOnly bool
+	var target exportTarget
+	var multiFile bool
+	var contextArgs []string
+	var diffOptions fileutil.DiffOptions
+	var colorMode string
+	var instructionsFlag string
+	var instructionsFile string
+	var stdinFilename string
+
+	editCmd := &cobra.Command{
+		Use:   "edit [flags] [instructions] [files, directories or globs...]",
+		Short: "Edit or refactor files using an LLM",
+		Long: `Edit or refactor files using an LLM based on instructions.
+Changes are staged for review before being applied.
+
+The instructions are the first argument, or come from -i or
+--instructions-file, in which case every argument is a file to edit. When no
+files are given and content is piped in, edit works as a filter: it reads the
+content from stdin and writes the edited content to stdout, without prompting.
+--stdin-filename names the content so that, for example, Go output is gofmt'd.
+
+Directories are edited recursively and quoted globs may use "**", e.g.
+"internal/**/*.go". Files ignored by git and binary files are skipped. Use
+--include and --exclude to filter the files found, and --git-tracked or
+--changed-since to limit them to tracked files or files changed since a ref;
+with either selector and no targets, the current directory is used.
+
+By default the model returns each file in full. With --mode search-replace or
+--mode udiff it returns targeted edits instead, which is faster and cheaper for
+large files; edits that can't be located in the file are reported and the file
+is left unstaged. --mode auto uses search/replace edits for files over
+300 lines.
+
+Each file is normally edited on its own. With --multi-file, all the files are
+sent in one request together with the other files of their packages and any
+--context files, and the model returns consistent edits for several files at
+once; the edit is staged only if every file's edits apply.
+
+With --fix-until-green, files that fail gofmt, --typecheck or --verify are
+sent back to the model together with the failure output, up to
+--max-iterations times or until --token-budget is spent. Only the final
+attempt is staged, and a transcript of every attempt is saved.
+
+With --stage-only, the staged files are saved instead of being applied; review
+and apply them later with "llm-tool staged". --to-branch, --to-patch and
+--to-stash leave the working tree alone and commit the changes on a new branch,
+write them as a patch for "git apply", or stash them. The --to-branch commit
+skips the repository's pre-commit, commit-msg and llm-tool hooks.`,
+		Example: `  llm-tool edit "Add doc comments" internal/store/
+  llm-tool edit -i "Add doc comments" internal/store/ cmd/main.go
+  llm-tool edit --instructions-file plan.md --multi-file internal/store/
+  cat main.go | llm-tool edit -i "Use log/slog" --stdin-filename main.go > new.go`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var files []string
+
+			switch mode {
+			case editModeWhole, editModeAuto, llm.EditModeSearchReplace, llm.EditModeUnifiedDiff:
+			default:
+				return fmt.Errorf("unknown edit mode %q: use whole, search-replace, udiff or auto", mode)
+			}
+			if onTruncate != "continue" && onTruncate != "fail" {
+				return fmt.Errorf("unknown --on-truncate value %q: use continue or fail", onTruncate)
+			}
+			if interactive && applyChanges {
+				return fmt.Errorf("--interactive and --yes cannot be used together")
+			}
+			if stageOnly && (interactive || applyChanges) {
+				return fmt.Errorf("--stage-only cannot be used with --interactive or --yes")
+			}
+			if len(contextArgs) > 0 {
+				multiFile = true
+			}
+			if multiFile && mode == llm.EditModeUnifiedDiff {
+				return fmt.Errorf("--mode udiff cannot be used with --multi-file")
+			}
+			if err := target.validate(); err != nil {
+				return err
+			}
+			if target.set() && (stageOnly || outputDir != "") {
+				return fmt.Errorf("--to-branch, --to-patch and --to-stash cannot be used with --stage-only or --output")
+			}
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1")
+			}
+			if fixUntilGreen && maxIterations < 1 {
+				return fmt.Errorf("--max-iterations must be at least 1")
+			}
+			switch diffOptions.Style {
+			case fileutil.DiffStyleAuto, fileutil.DiffStyleUnified, fileutil.DiffStyleSideBySide:
+			default:
+				return fmt.Errorf("unknown --diff-style %q: use auto, unified or side-by-side", diffOptions.Style)
+			}
+			if diffOptions.Context < 0 {
+				return fmt.Errorf("--diff-context can't be negative")
+			}
+			if err := setColorMode(colorMode); err != nil {
+				return err
+			}
+
+			// With no targets and piped input, edit works as a filter from
+			// stdin to stdout. That needs stdin for the content, so refuse to
+			// read the instructions from it before anything is consumed.
+			info, _ := os.Stdin.Stat()
+			isPipe := (info.Mode() & os.ModeCharDevice) == 0
+			if instructionsFile == "-" && len(args) == 0 && isPipe && !selection.gitTracked && selection.changedSince == "" {
+				return fmt.Errorf("--instructions-file can't be stdin when the content is piped in")
+			}
+
+			instructions, targets, err := editInstructions(args, instructionsFlag, instructionsFile)
+			if err != nil {
+				return err
+			}
+
+			filter := len(targets) == 0 && isPipe && !selection.gitTracked && selection.changedSince == ""
+			if filter {
+				for _, name := range []string{"interactive", "multi-file", "context", "stage-only", "to-branch", "to-patch", "to-stash", "output", "typecheck", "verify", "fix-until-green"} {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("--%s can't be used when editing stdin", name)
+					}
+				}
+			} else {
+				if len(targets) == 0 && (selection.gitTracked || selection.changedSince != "") {
+					targets = []string{"."}
+				}
+				if len(targets) == 0 {
+					return fmt.Errorf("please provide both instructions and at least one file, or pipe the content to edit")
+				}
+
+				files, err = expandEditTargets(targets, selection)
+				if err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return fmt.Errorf("no files to edit")
+				}
+			}
+
+			if instructions == "" {
+				return fmt.Errorf("refactoring instructions cannot be empty")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if provider == "" {
+				provider = cfg.DefaultProvider
+			}
+
+			// If datasource is provided, update the config temporarily
+			if provider == "cboe" && datasource != "" {
+				cfg.CBOE.Datasource = datasource
+			}
+
+			baseClient, err := llm.NewClient(provider, cfg)
+			if err != nil {
+				return err
+			}
+			// The fix loop needs token accounting to enforce --token-budget
+			client := llm.NewMeteredClient(baseClient)
+
+			opts := editOptions{
+				instructions: instructions,
+				mode:         mode,
+				model:        model,
+				onTruncate:   onTruncate,
+				allowShrink:  allowShrink,
+
+				strictBrackets: strictBrackets,
+			}
+			if filter {
+				return editStdin(cmd.Context(), client, stdinFilename, opts)
+			}
+
+			// Create staging area for processed files
+			stagingArea, err := fileutil.NewStagingArea()
+			if err != nil {
+				return err
+			}
+			defer stagingArea.Cleanup()
+			// Mirror paths relative to the repository root, or the current directory
+			if root, err := git.RepoRoot(""); err == nil {
+				stagingArea.BaseDir = root
+			}
+			stagingArea.HistoryDir = config.GetHistoryDir()
+			stagingArea.Description = instructions
+			stagingArea.DiffOptions = diffOptions
+
+			// Process the files concurrently, within the provider's limit
+			workers := jobs
+			if limit := cfg.MaxConcurrency(provider); limit > 0 && workers > limit {
+				workers = limit
+			}
+			fmt.Printf("Processing %d files with the following instructions:\n%s\n\n", len(files), instructions)
+			var results []editResult
+			if multiFile {
+				contextFiles, err := expandEditTargets(contextArgs, targetSelection{})
+				if err != nil {
+					return err
+				}
+				results = editMultiFile(cmd.Context(), client, stagingArea.BaseDir, files, contextFiles, nil, opts)
+			} else {
+				results = editFiles(cmd.Context(), client, files, opts, workers)
+			}
+
+			// Stage the files that succeeded, in the order given
+			var failed []editResult
+			for _, result := range results {
+				if result.err != nil {
+					failed = append(failed, result)
+					continue
+				}
+
+				filename := result.filename
+				if result.deleted {
+					if outputDir != "" {
+						fmt.Printf("Not deleting %s, since changes are written to %s\n", filename, outputDir)
+						continue
+					}
+					if err := stagingArea.StageDeletion(filename); err != nil {
+						return fmt.Errorf("failed to stage deletion of %s: %w", filename, err)
+					}
+					continue
+				}
+
+				outputFilename := filename
+				if outputDir != "" {
+					// If output directory is specified, write there instead,
+					// reproducing the tree relative to the repository root
+					outputFilename = filepath.Join(outputDir, fileutil.MirrorPath(stagingArea.BaseDir, filename))
+				}
+				isNew := !fileExists(outputFilename)
+
+				_, err = stagingArea.StageFile(outputFilename, result.content, isNew)
+				if err != nil {
+					return fmt.Errorf("failed to stage file %s: %w", outputFilename, err)
+				}
+				if !isNew && outputFilename == filename {
+					// Detect changes made to the file while the model was working
+					stagingArea.SetOriginal(outputFilename, result.original)
+				}
+			}
+
+			if len(failed) > 0 {
+				showEditFailures(failed)
+			}
+			if len(stagingArea.Files) == 0 {
+				return fmt.Errorf("no files were edited successfully")
+			}
+
+			checks := stagedChecks{typecheck: typecheck, verifyCommand: verifyCommand}
+			verification, err := checkStaged(cmd.Context(), stagingArea, checks)
+			if err != nil {
+				return err
+			}
+
+			if fixUntilGreen {
+				limits := fixLimits{maxIterations: maxIterations, tokenBudget: tokenBudget}
+				verification, err = fixStaged(cmd.Context(), client, stagingArea, opts, checks, limits, verification)
+				if err != nil {
+					return err
+				}
+			}
+
+			if stageOnly {
+				return saveStaged(stagingArea, verification, len(failed))
+			}
+
+			if interactive {
+				if verification != nil {
+					showVerification(verification)
+				}
+
+				quit, err := interactiveReview(cmd.Context(), client, stagingArea, opts)
+				if err != nil {
+					return err
+				}
+				if quit || len(stagingArea.Files) == 0 {
+					fmt.Println("Changes not applied.")
+					return nil
+				}
+
+				// Manual edits and follow-up prompts haven't been checked yet
+				verification, err = checkStaged(cmd.Context(), stagingArea, checks)
+				if err != nil {
+					return err
+				}
+				if !checksPassed(stagingArea, verification) {
+					for _, file := range stagingArea.Files {
+						if len(file.Problems) > 0 {
+							fmt.Printf("\n%s:\n", file.OriginalPath)
+							printProblems(file.Problems)
+						}
+					}
+					if verification != nil {
+						showVerification(verification)
+					}
+
+					fmt.Print("\nChecks failed. Apply the selected changes anyway? [y/N] ")
+					var response string
+					fmt.Scanln(&response)
+					if response != "y" && response != "Y" {
+						fmt.Println("Changes not applied.")
+						return nil
+					}
+				}
+			} else {
+				// Show diffs and prompt for confirmation
+				fmt.Println("\nReview of changes:")
+				if err := stagingArea.ShowDiff(); err != nil {
+					return fmt.Errorf("failed to show diffs: %w", err)
+				}
+
+				if verification != nil {
+					showVerification(verification)
+				}
+			}
+
+			checksFailed := stagingArea.HasProblems() || (verification != nil && !verification.Passed)
+			if applyChanges && checksFailed {
+				return fmt.Errorf("not applying changes because verification failed")
+			}
+
+			if !applyChanges && !interactive {
+				// Ask for confirmation
+				question := "Apply these changes?"
+				if target.set() {
+					question = target.question()
+				}
+				fmt.Printf("\n%s [y/N] ", question)
+				var response string
+				fmt.Scanln(&response)
+
+				if response != "y" && response != "Y" {
+					fmt.Println("Changes not applied.")
+					return nil
+				}
+			}
+
+			if target.set() {
+				// The working tree isn't touched, so there is nothing to conflict with
+				if err := exportStaged(stagingArea, target); err != nil {
+					return err
+				}
+				if len(failed) > 0 {
+					return fmt.Errorf("exported changes to %d files, but %d files failed", len(stagingArea.Files), len(failed))
+				}
+				return nil
+			}
+
+			proceed, err := resolveConflicts(stagingArea, applyChanges)
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				fmt.Println("Changes not applied.")
+				return nil
+			}
+
+			// Apply changes
+			if err := stagingArea.ApplyChanges(); err != nil {
+				return fmt.Errorf("failed to apply changes: %w", err)
+			}
+
+			if len(failed) > 0 {
+				return fmt.Errorf("applied changes to %d files, but %d files failed", len(stagingArea.Files), len(failed))
+			}
+			fmt.Println("All changes applied successfully.")
+			return nil
+		},
+	}
+
+	editCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
+	editCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	editCmd.Flags().StringVarP(&instructionsFlag, "instructions", "i", "", "Instructions for the edit; every argument is then a file to edit")
+	editCmd.Flags().StringVar(&instructionsFile, "instructions-file", "", "Read the instructions from a file (- for stdin)")
+	editCmd.Flags().StringVar(&stdinFilename, "stdin-filename", "", "Name of the content piped to stdin, shown to the model and used to format it")
+	editCmd.Flags().BoolVarP(&applyChanges, "yes", "y", false, "Apply changes without confirmation")
+	editCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory for refactored files")
+	editCmd.Flags().StringVarP(&datasource, "datasource", "d", "", "Datasource to use (CBOE only)")
+	editCmd.Flags().StringVar(&mode, "mode", editModeWhole, "Edit mode: whole, search-replace, udiff or auto")
+	editCmd.Flags().StringVar(&onTruncate, "on-truncate", "continue", "When output hits the token limit: continue or fail")
+	editCmd.Flags().BoolVar(&allowShrink, "allow-shrink", false, "Don't flag edits that remove more than half of a file")
+	editCmd.Flags().BoolVar(&strictBrackets, "strict-brackets", false, "Fail edits to non-Go files whose brackets no longer balance instead of warning")
+	editCmd.Flags().BoolVar(&typecheck, "typecheck", false, "Run go vet on packages with staged Go files")
+	editCmd.Flags().StringVar(&verifyCommand, "verify", "", "Command to run against a temporary copy of the staged tree, e.g. \"go test ./...\"")
+	editCmd.Flags().BoolVar(&stageOnly, "stage-only", false, "Save the staged changes for \"llm-tool staged\" instead of applying them")
+	editCmd.Flags().StringVar(&target.branch, "to-branch", "", "Commit the changes on a new branch instead of applying them")
+	editCmd.Flags().StringVar(&target.patchFile, "to-patch", "", "Write the changes to a patch file (- for stdout) instead of applying them")
+	editCmd.Flags().BoolVar(&target.stash, "to-stash", false, "Stash the changes instead of applying them")
+	editCmd.Flags().BoolVar(&interactive, "interactive", false, "Review each file or hunk before applying, like git add -p")
+	editCmd.Flags().BoolVar(&multiFile, "multi-file", false, "Edit all files in one request, with the rest of their packages for reference")
+	editCmd.Flags().StringSliceVar(&contextArgs, "context", nil, "Files sent with a multi-file edit for reference; implies --multi-file")
+	editCmd.Flags().IntVarP(&jobs, "jobs", "j", 4, "Number of files to edit in parallel, capped by the provider's maxConcurrency")
+	editCmd.Flags().StringSliceVar(&selection.include, "include", nil, "Only edit files matching these globs")
+	editCmd.Flags().StringSliceVar(&selection.exclude, "exclude", nil, "Skip files matching these globs")
+	editCmd.Flags().BoolVar(&selection.gitTracked, "git-tracked", false, "Only edit files tracked by git")
+	editCmd.Flags().StringVar(&selection.changedSince, "changed-since", "", "Only edit files changed since this git ref")
+	editCmd.Flags().BoolVar(&fixUntilGreen, "fix-until-green", false, "Feed check failures back to the model until the staged changes pass")
+	editCmd.Flags().IntVar(&maxIterations, "max-iterations", 3, "Maximum number of fix attempts with --fix-until-green")
+	editCmd.Flags().IntVar(&tokenBudget, "token-budget", 0, "Approximate token limit for the whole edit with --fix-until-green (0 for no limit)")
+	editCmd.Flags().IntVar(&diffOptions.Context, "diff-context", fileutil.DefaultDiffContext, "Number of unchanged lines shown around each change")
+	editCmd.Flags().StringVar(&diffOptions.Style, "diff-style", fileutil.DiffStyleAuto, "Diff display: unified, side-by-side, or auto to go side by side on wide terminals")
+	editCmd.Flags().StringVar(&colorMode, "color", "auto", "Color output: auto, always or never")
+
+	return editCmd
+}
+
+// targetSelection holds the flags that select which files edit expands targets to
+type targetSelection struct {
+	include      []string
+	exclude      []string
+	gitTracked   bool
+	changedSince string
+}
+
+// expandEditTargets expands file, directory and glob arguments into the files
+// to edit, honouring .gitignore when run inside a git repository
+func expandEditTargets(targets []string, selection targetSelection) ([]string, error) {
+	opts := fileutil.TargetOptions{
+		Include: selection.include,
+		Exclude: selection.exclude,
+	}
+
+	inRepo := false
+	if visible, err := git.VisibleFiles(""); err == nil {
+		opts.Visible = visible
+		inRepo = true
+	}
+
+	if (selection.gitTracked || selection.changedSince != "") && !inRepo {
+		return nil, fmt.Errorf("--git-tracked and --changed-since require a git repository")
+	}
+
+	var selected []string
+	if selection.gitTracked {
+		tracked, err := git.TrackedFiles("")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tracked files: %w", err)
+		}
+		selected = tracked
+	}
+	if selection.changedSince != "" {
+		changed, err := git.ChangedSince(selection.changedSince, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files changed since %s: %w", selection.changedSince, err)
+		}
+		if selected != nil {
+			changed = intersect(selected, changed)
+		}
+		selected = changed
+	}
+	if selection.gitTracked || selection.changedSince != "" {
+		// An empty selection must still restrict the targets
+		opts.Allowed = append([]string{}, selected...)
+	}
+
+	files, binary, err := fileutil.ExpandTargets(targets, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range binary {
+		fmt.Printf("Skipping binary file: %s\n", path)
+	}
+	return files, nil
+}
+
+// intersect returns the elements of a that are also in b
+func intersect(a []string, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var result []string
+	for _, s := range a {
+		if inB[s] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// editContent asks the model to edit a single file's content according to opts.mode
+func editContent(ctx context.Context, client llm.Client, filename string, content string, opts editOptions) (string, error) {
+	mode := opts.mode
+	if mode == editModeAuto {
+		mode = editModeWhole
+		if strings.Count(content, "\n") >= autoPatchLines {
+			mode = llm.EditModeSearchReplace
+		}
+	}
+
+	var edited string
+	if mode == editModeWhole {
+		refactored, err := client.RefactorFile(ctx, filename, content, opts.instructions, opts.model)
+		if errors.Is(err, llm.ErrOutputTruncated) && opts.onTruncate == "continue" {
+			opts.note("  Output for %s hit the token limit, asking the model to continue...\n", filename)
+			refactored, err = llm.ContinueRefactor(ctx, client, filename, content, opts.instructions, refactored, opts.model)
+		}
+		if err != nil {
+			return "", err
+		}
+		edited = refactored
+	} else {
+		patched, failures, err := llm.PatchFile(ctx, client, filename, content, opts.instructions, mode, opts.model)
+		if err != nil {
+			return "", err
+		}
+		if len(failures) > 0 {
+			return "", fmt.Errorf("%d of the model's edits could not be applied:\n%s", len(failures), llm.FormatPatchFailures(failures))
+		}
+		if patched == content {
+			opts.note("  No changes made to %s\n", filename)
+		}
+		edited = patched
+	}
+
+	problems, warnings := llm.DetectLostContent(filename, content, edited, opts.lostContentChecks())
+	if len(problems) > 0 {
+		return "", fmt.Errorf("output appears to be missing content:\n  %s", strings.Join(problems, "\n  "))
+	}
+	for _, warning := range warnings {
+		opts.note("  Warning: %s: %s (use --strict-brackets to fail instead)\n", filename, warning)
+	}
+	return edited, nil
+}
+
+// stagedChecks selects the checks run against staged files
+type stagedChecks struct {
+	typecheck     bool
+	verifyCommand string
+}
+
+// checkStaged gofmts staged Go files and runs the selected checks, replacing
+// any problems recorded by an earlier run. It returns the result of the
+// verification command, or nil if none was given.
+func checkStaged(ctx context.Context, stagingArea *fileutil.StagingArea, checks stagedChecks) (*verify.Result, error) {
+	stagingArea.ClearProblems()
+
+	files := append([]fileutil.StagedFile(nil), stagingArea.Files...)
+	for _, file := range files {
+		if filepath.Ext(file.OriginalPath) != ".go" || file.IsDelete {
+			continue
+		}
+
+		formatted, err := verify.FormatGo(file.OriginalPath, file.Content)
+		if err != nil {
+			stagingArea.AddProblems(file.OriginalPath, err.Error())
+			continue
+		}
+		if formatted != file.Content {
+			if _, err := stagingArea.StageFile(file.OriginalPath, formatted, file.IsNew); err != nil {
+				return nil, fmt.Errorf("failed to stage file %s: %w", file.OriginalPath, err)
+			}
+		}
+	}
+
+	if checks.typecheck {
+		fmt.Println("\nType-checking staged Go files...")
+		if err := typeCheckStaged(ctx, stagingArea); err != nil {
+			return nil, err
+		}
+	}
+
+	if checks.verifyCommand == "" {
+		return nil, nil
+	}
+	fmt.Printf("\nRunning %q against the staged changes...\n", checks.verifyCommand)
+	return verifyStaged(ctx, stagingArea, checks.verifyCommand)
+}
+
+// typeCheckStaged runs go vet on the packages of staged Go files and records
+// failures as problems on the files in each failing package
+func typeCheckStaged(ctx context.Context, stagingArea *fileutil.StagingArea) error {
+	// Deleted files have no staged path, which removes them in the overlay
+	files := make(map[string]string)
+	for _, file := range stagingArea.Files {
+		files[file.OriginalPath] = file.StagedPath
+	}
+
+	results, err := verify.TypeCheck(ctx, files)
+	if err != nil {
+		return fmt.Errorf("failed to type-check: %w", err)
+	}
+
+	for dir, result := range results {
+		if result.Passed {
+			continue
+		}
+		for _, file := range stagingArea.Files {
+			abs, err := filepath.Abs(file.OriginalPath)
+			if err == nil && filepath.Dir(abs) == dir && filepath.Ext(abs) == ".go" {
+				stagingArea.AddProblems(file.OriginalPath, "go vet failed:\n"+verify.Summarize(result.Output, 20))
+			}
+		}
+	}
+	return nil
+}
+
+// verifyStaged runs command against a temporary copy of the repository with
+// the staged changes applied
+func verifyStaged(ctx context.Context, stagingArea *fileutil.StagingArea, command string) (*verify.Result, error) {
+	root, err := git.RepoRoot("")
+	if err != nil {
+		// Not in a git repository: copy the current directory instead
+		root = "."
+	}
+
+	files := make(map[string]string)
+	var deleted []string
+	for _, file := range stagingArea.Files {
+		if file.IsDelete {
+			deleted = append(deleted, file.OriginalPath)
+			continue
+		}
+		files[file.OriginalPath] = file.Content
+	}
+
+	result, err := verify.RunInCopy(ctx, root, ".", files, deleted, command, verify.DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run verification: %w", err)
+	}
+	return &result, nil
+}
+
+// showVerification prints the outcome of a verification command
+func showVerification(result *verify.Result) {
+	if result.Passed {
+		color.New(color.FgGreen).Printf("\nVerification passed: %s\n", result.Command)
+		return
+	}
+
+	red := color.New(color.FgRed)
+	red.Printf("\nVerification failed: %s\n", result.Command)
+	fmt.Println(verify.Summarize(result.Output, 40))
+}
+
+// fileExists checks if a file exists and is not a directory
+func fileExists(filename string) bool {
+	if filename == "-" {
+		return false
+	}
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// saveStaged saves the staging area as a session to review and apply later
+func saveStaged(stagingArea *fileutil.StagingArea, verification *verify.Result, failed int) error {
+	for _, file := range stagingArea.Files {
+		if len(file.Problems) > 0 {
+			fmt.Printf("\n%s:\n", file.OriginalPath)
+			printProblems(file.Problems)
+		}
+	}
+	if verification != nil {
+		showVerification(verification)
+	}
+
+	session, err := stagingArea.SaveSession(config.GetStagingDir())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nStaged %d files as session %s\n", len(session.Files), session.ID)
+	fmt.Printf("Review with: llm-tool staged show %s\n", session.ID)
+	fmt.Printf("Apply with:  llm-tool staged apply %s\n", session.ID)
+
+	if failed > 0 {
+		return fmt.Errorf("staged %d files, but %d files failed", len(session.Files), failed)
+	}
+	return nil
+}
+
+// setColorMode forces colored output on or off. In auto mode colors are used
+// when stdout is a terminal and NO_COLOR is not set.
+func setColorMode(mode string) error {
+	switch mode {
+	case "auto":
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf("unknown --color value %q: use auto, always or never", mode)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+)
+
+// exportTarget is where staged changes go instead of the working tree
+type exportTarget struct {
+	branch    string
+	patchFile string
+	stash     bool
+}
+
+// set reports whether the changes are exported rather than applied
+func (t exportTarget) set() bool {
+	return t.branch != "" || t.patchFile != "" || t.stash
+}
+
+// validate checks that at most one target was chosen
+func (t exportTarget) validate() error {
+	count := 0
+	for _, chosen := range []bool{t.branch != "", t.patchFile != "", t.stash} {
+		if chosen {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("only one of --to-branch, --to-patch and --to-stash can be used")
+	}
+	return nil
+}
+
+// question returns the confirmation prompt for exporting the changes
+func (t exportTarget) question() string {
+	switch {
+	case t.branch != "":
+		return fmt.Sprintf("Commit these changes to branch %s?", t.branch)
+	case t.patchFile != "":
+		return fmt.Sprintf("Write these changes to %s?", t.patchFile)
+	default:
+		return "Stash these changes?"
+	}
+}
+
+// exportStaged writes the staged changes to a patch file, or commits or
+// stashes them from a temporary worktree checked out at HEAD, leaving the
+// working tree untouched
+func exportStaged(stagingArea *fileutil.StagingArea, target exportTarget) error {
+	patch, err := stagingArea.Patch()
+	if err != nil {
+		return fmt.Errorf("failed to create patch: %w", err)
+	}
+	if patch == "" {
+		return fmt.Errorf("no changes to export")
+	}
+
+	if target.patchFile != "" {
+		if target.patchFile == "-" {
+			fmt.Print(patch)
+			return nil
+		}
+		if err := os.WriteFile(target.patchFile, []byte(patch), 0644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+		fmt.Printf("Wrote patch to %s (apply with: git apply %s)\n", target.patchFile, target.patchFile)
+		return nil
+	}
+
+	root := stagingArea.BaseDir
+	tmpDir, err := os.MkdirTemp("", "llm-tool-worktree-*")
+	if err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	worktree := filepath.Join(tmpDir, "worktree")
+	if err := git.AddWorktree(root, worktree, target.branch, "HEAD"); err != nil {
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+	defer git.RemoveWorktree(root, worktree)
+
+	// Don't leave behind a branch without the changes
+	fail := func(err error) error {
+		if target.branch != "" {
+			git.RemoveWorktree(root, worktree)
+			git.DeleteBranch(root, target.branch)
+		}
+		return err
+	}
+
+	if err := git.ApplyPatch(worktree, patch); err != nil {
+		return fail(fmt.Errorf("failed to apply the changes to HEAD; commit local changes to the edited files first, or use --to-patch: %w", err))
+	}
+
+	summary := summarizeDescription(stagingArea.Description)
+	if target.branch != "" {
+		message := "llm-tool: " + summary + "\n"
+		if description := strings.TrimSpace(stagingArea.Description); description != summary {
+			message += "\n" + description + "\n"
+		}
+		hash, err := git.CommitIndex(worktree, message)
+		if err != nil {
+			return fail(fmt.Errorf("failed to commit changes: %w", err))
+		}
+		fmt.Printf("Committed changes to %d files on branch %s (%s)\n", len(stagingArea.Files), target.branch, hash)
+		return nil
+	}
+
+	if err := git.StashPush(worktree, "llm-tool: "+summary); err != nil {
+		return fmt.Errorf("failed to stash changes: %w", err)
+	}
+	fmt.Println("Stashed the changes (inspect with: git stash show -p, apply with: git stash apply)")
+	return nil
+}
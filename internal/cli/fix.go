@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/EricBriscoe/llm-tool/internal/verify"
+	"github.com/fatih/color"
+)
+
+// fixLimits bounds the --fix-until-green loop
+type fixLimits struct {
+	maxIterations int
+	tokenBudget   int // 0 for no limit
+}
+
+// fixStaged re-prompts the model with the failures of the staged files until
+// the checks pass or a limit is reached. Each attempt replaces the staged
+// content, so only the last attempt is left staged. A transcript of every
+// attempt is written to the config directory.
+func fixStaged(ctx context.Context, client *llm.MeteredClient, stagingArea *fileutil.StagingArea, opts editOptions, checks stagedChecks, limits fixLimits, verification *verify.Result) (*verify.Result, error) {
+	transcript := newFixTranscript(opts.instructions)
+	transcript.addAttempt(0, nil, stagingArea, verification)
+
+	yellow := color.New(color.FgYellow)
+	attempt := 0
+	for !checksPassed(stagingArea, verification) {
+		if attempt >= limits.maxIterations {
+			yellow.Printf("\nChecks still failing after %d fix attempts; keeping the last attempt\n", attempt)
+			break
+		}
+		if limits.tokenBudget > 0 && client.Tokens() >= limits.tokenBudget {
+			yellow.Printf("\nToken budget of %d exhausted after %d fix attempts; keeping the last attempt\n", limits.tokenBudget, attempt)
+			break
+		}
+		attempt++
+
+		targets := failingFiles(stagingArea, verification)
+		fmt.Printf("\nFix attempt %d of %d: re-prompting for %d files\n", attempt, limits.maxIterations, len(targets))
+
+		var errs []string
+		for _, file := range targets {
+			fmt.Printf("  Fixing %s\n", file.OriginalPath)
+
+			fixOpts := opts
+			fixOpts.instructions = llm.BuildFixInstructions(opts.instructions, describeFailures(file, verification))
+			edited, err := editContent(ctx, client, file.OriginalPath, file.Content, fixOpts)
+			if err != nil {
+				// Keep the previous attempt for this file and try again next round
+				msg := fmt.Sprintf("%s: %v", file.OriginalPath, err)
+				yellow.Printf("  Fix for %s failed: %v\n", file.OriginalPath, err)
+				errs = append(errs, msg)
+				continue
+			}
+
+			if _, err := stagingArea.StageFile(file.OriginalPath, edited, file.IsNew); err != nil {
+				return nil, fmt.Errorf("failed to stage file %s: %w", file.OriginalPath, err)
+			}
+		}
+
+		var err error
+		verification, err = checkStaged(ctx, stagingArea, checks)
+		if err != nil {
+			return nil, err
+		}
+		transcript.addAttempt(attempt, errs, stagingArea, verification)
+	}
+
+	if attempt > 0 && checksPassed(stagingArea, verification) {
+		color.New(color.FgGreen).Printf("\nAll checks passed after %d fix attempts\n", attempt)
+	}
+	fmt.Printf("Approximate tokens used: %d\n", client.Tokens())
+
+	path, err := transcript.save()
+	if err != nil {
+		yellow.Printf("Failed to save fix transcript: %v\n", err)
+	} else {
+		fmt.Printf("Transcript of fix attempts saved to %s\n", path)
+	}
+
+	return verification, nil
+}
+
+// checksPassed reports whether the staged files have no problems and the
+// verification command, if any, succeeded
+func checksPassed(stagingArea *fileutil.StagingArea, verification *verify.Result) bool {
+	return !stagingArea.HasProblems() && (verification == nil || verification.Passed)
+}
+
+// failingFiles returns the staged files with problems. A failing verification
+// command can't be attributed to a file, so then every staged file is returned.
+func failingFiles(stagingArea *fileutil.StagingArea, verification *verify.Result) []fileutil.StagedFile {
+	var files []fileutil.StagedFile
+	for _, file := range stagingArea.Files {
+		if len(file.Problems) > 0 {
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 && verification != nil && !verification.Passed {
+		files = append(files, stagingArea.Files...)
+	}
+	return files
+}
+
+// describeFailures renders the problems of a staged file and the output of a
+// failed verification command for the fix prompt
+func describeFailures(file fileutil.StagedFile, verification *verify.Result) string {
+	var sb strings.Builder
+	for _, problem := range file.Problems {
+		sb.WriteString(strings.TrimRight(problem, "\n"))
+		sb.WriteString("\n")
+	}
+
+	if verification != nil && !verification.Passed {
+		fmt.Fprintf(&sb, "Output of %q:\n%s\n", verification.Command, verify.Summarize(verification.Output, 80))
+	}
+	return sb.String()
+}
+
+// fixTranscript records the attempts made by the fix loop as Markdown
+type fixTranscript struct {
+	started time.Time
+	sb      strings.Builder
+}
+
+// newFixTranscript starts a transcript for an edit with the given instructions
+func newFixTranscript(instructions string) *fixTranscript {
+	t := &fixTranscript{started: time.Now()}
+	fmt.Fprintf(&t.sb, "# llm-tool edit transcript\n\nStarted: %s\n\n## Instructions\n\n%s\n", t.started.Format(time.RFC3339), instructions)
+	return t
+}
+
+// addAttempt records the staged content and check results after an attempt.
+// Attempt 0 is the initial edit.
+func (t *fixTranscript) addAttempt(attempt int, errs []string, stagingArea *fileutil.StagingArea, verification *verify.Result) {
+	if attempt == 0 {
+		t.sb.WriteString("\n## Initial edit\n")
+	} else {
+		fmt.Fprintf(&t.sb, "\n## Fix attempt %d\n", attempt)
+	}
+
+	for _, msg := range errs {
+		fmt.Fprintf(&t.sb, "\nModel error: %s\n", msg)
+	}
+
+	for _, file := range stagingArea.Files {
+		fmt.Fprintf(&t.sb, "\n### %s\n\n````\n%s````\n", file.OriginalPath, ensureNewline(file.Content))
+		for _, problem := range file.Problems {
+			fmt.Fprintf(&t.sb, "\nProblem:\n\n````\n%s````\n", ensureNewline(problem))
+		}
+	}
+
+	if verification != nil {
+		status := "passed"
+		if !verification.Passed {
+			status = "failed"
+		}
+		fmt.Fprintf(&t.sb, "\n### %s (%s)\n\n````\n%s````\n", verification.Command, status, ensureNewline(verification.Output))
+	}
+}
+
+// save writes the transcript under the config directory and returns its path
+func (t *fixTranscript) save() (string, error) {
+	dir := filepath.Join(config.GetConfigDir(), "transcripts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "edit-"+t.started.Format("20060102-150405")+".md")
+	if err := os.WriteFile(path, []byte(t.sb.String()), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ensureNewline terminates text with a newline so that it can be fenced
+func ensureNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
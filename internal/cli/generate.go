@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// newGenerateCmd creates the command that writes new files from instructions
+func newGenerateCmd() *cobra.Command {
+	var provider string
+	var model string
+	var datasource string
+	var into []string
+	var contextArgs []string
+	var applyChanges bool
+
+	generateCmd := &cobra.Command{
+		Use:   "generate [instructions]",
+		Short: "Create new files from instructions",
+		Long: `Ask the model to write the files given with --into, which must not exist
+yet. The other files in their directories with the same extension (such as the
+rest of a Go package) and any --context files are sent for reference.
+
+The model may also change or delete related files when the instructions call
+for it. Everything is shown as a diff and applied only after confirmation.`,
+		Example: `  llm-tool generate "Write table tests for parser.go" --into parser_test.go`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instructions := args[0]
+			for _, path := range into {
+				if fileExists(path) {
+					return fmt.Errorf("%s already exists; use \"llm-tool edit\" to change it", path)
+				}
+			}
+
+			var contextFiles []string
+			if len(contextArgs) > 0 {
+				var err error
+				contextFiles, err = expandEditTargets(contextArgs, targetSelection{})
+				if err != nil {
+					return err
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if provider == "" {
+				provider = cfg.DefaultProvider
+			}
+
+			// If datasource is provided, update the config temporarily
+			if provider == "cboe" && datasource != "" {
+				cfg.CBOE.Datasource = datasource
+			}
+
+			client, err := llm.NewClient(provider, cfg)
+			if err != nil {
+				return err
+			}
+
+			stagingArea, err := fileutil.NewStagingArea()
+			if err != nil {
+				return err
+			}
+			defer stagingArea.Cleanup()
+			if root, err := git.RepoRoot(""); err == nil {
+				stagingArea.BaseDir = root
+			}
+			stagingArea.HistoryDir = config.GetHistoryDir()
+			stagingArea.Description = instructions
+
+			opts := editOptions{instructions: instructions, mode: editModeWhole, model: model}
+			results := editMultiFile(cmd.Context(), client, stagingArea.BaseDir, nil, contextFiles, into, opts)
+			for _, result := range results {
+				if result.err != nil {
+					return result.err
+				}
+			}
+
+			created := make(map[string]bool)
+			for _, result := range results {
+				if result.deleted {
+					if err := stagingArea.StageDeletion(result.filename); err != nil {
+						return fmt.Errorf("failed to stage deletion of %s: %w", result.filename, err)
+					}
+					continue
+				}
+
+				isNew := !fileExists(result.filename)
+				if _, err := stagingArea.StageFile(result.filename, result.content, isNew); err != nil {
+					return fmt.Errorf("failed to stage file %s: %w", result.filename, err)
+				}
+				if !isNew {
+					stagingArea.SetOriginal(result.filename, result.original)
+				}
+				created[canonical(result.filename)] = true
+			}
+			for _, path := range into {
+				if !created[canonical(path)] {
+					fmt.Printf("The model did not write %s\n", path)
+				}
+			}
+
+			return reviewAndApply(cmd.Context(), stagingArea, applyChanges)
+		},
+	}
+
+	generateCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
+	generateCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
+	generateCmd.Flags().StringVarP(&datasource, "datasource", "d", "", "Datasource to use (CBOE only)")
+	generateCmd.Flags().StringSliceVar(&into, "into", nil, "New files to write (repeat or comma-separate for several)")
+	generateCmd.Flags().StringSliceVar(&contextArgs, "context", nil, "Files sent to the model for reference")
+	generateCmd.Flags().BoolVarP(&applyChanges, "yes", "y", false, "Apply changes without confirmation")
+	generateCmd.MarkFlagRequired("into")
+	return generateCmd
+}
+
+// reviewAndApply checks the staged files, shows their diffs and applies them
+// once confirmed, or without asking if assumeYes is set and the checks pass
+func reviewAndApply(ctx context.Context, stagingArea *fileutil.StagingArea, assumeYes bool) error {
+	if _, err := checkStaged(ctx, stagingArea, stagedChecks{}); err != nil {
+		return err
+	}
+
+	fmt.Println("\nReview of changes:")
+	if err := stagingArea.ShowDiff(); err != nil {
+		return fmt.Errorf("failed to show diffs: %w", err)
+	}
+
+	if assumeYes && stagingArea.HasProblems() {
+		return fmt.Errorf("not applying changes because verification failed")
+	}
+	if !assumeYes {
+		fmt.Print("\nApply these changes? [y/N] ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Changes not applied.")
+			return nil
+		}
+	}
+
+	proceed, err := resolveConflicts(stagingArea, assumeYes)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		fmt.Println("Changes not applied.")
+		return nil
+	}
+
+	if err := stagingArea.ApplyChanges(); err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/spf13/cobra"
+)
+
+// newUndoCmd creates the command that reverts an applied edit
+func newUndoCmd() *cobra.Command {
+	var force bool
+
+	undoCmd := &cobra.Command{
+		Use:   "undo [transaction-id]",
+		Short: "Revert the files changed by an applied edit",
+		Long: `Restore the files changed or deleted by an applied edit to their previous
+content and remove the files it created. Without an ID, the most recent edit
+that hasn't been undone is reverted.
+
+Files that were modified after the edit are not touched, and nothing is
+restored, unless --force is given. Use "llm-tool history" to list edits.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			historyDir := config.GetHistoryDir()
+
+			var tx *fileutil.Transaction
+			if len(args) == 1 {
+				loaded, err := fileutil.LoadTransaction(historyDir, args[0])
+				if err != nil {
+					return err
+				}
+				tx = loaded
+			} else {
+				txs, err := fileutil.ListTransactions(historyDir)
+				if err != nil {
+					return err
+				}
+				for _, candidate := range txs {
+					if candidate.UndoneAt == nil {
+						tx = candidate
+						break
+					}
+				}
+				if tx == nil {
+					return fmt.Errorf("no edits to undo")
+				}
+			}
+
+			restored, err := fileutil.UndoTransaction(historyDir, tx, force)
+			for _, path := range restored {
+				fmt.Printf("Restored %s\n", path)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Undid transaction %s (%s)\n", tx.ID, summarizeDescription(tx.Description))
+			return nil
+		},
+	}
+
+	undoCmd.Flags().BoolVar(&force, "force", false, "Restore files even if they changed after the edit")
+	return undoCmd
+}
+
+// newHistoryCmd creates the command that lists applied edits
+func newHistoryCmd() *cobra.Command {
+	var limit int
+	var verbose bool
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List applied edits that can be undone",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			txs, err := fileutil.ListTransactions(config.GetHistoryDir())
+			if err != nil {
+				return err
+			}
+			if len(txs) == 0 {
+				fmt.Println("No edits recorded.")
+				return nil
+			}
+
+			if limit > 0 && len(txs) > limit {
+				txs = txs[:limit]
+			}
+
+			for _, tx := range txs {
+				status := ""
+				if tx.UndoneAt != nil {
+					status = " (undone)"
+				}
+				fmt.Printf("%s  %s  %d files  %s%s\n", tx.ID, tx.Time.Format("2006-01-02 15:04"), len(tx.Files), summarizeDescription(tx.Description), status)
+
+				if verbose {
+					for _, file := range tx.Files {
+						marker := "M"
+						if file.Created {
+							marker = "A"
+						} else if file.Deleted {
+							marker = "D"
+						}
+						fmt.Printf("    %s %s\n", marker, file.Path)
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	historyCmd.Flags().IntVarP(&limit, "limit", "n", 20, "Maximum number of edits to list (0 for all)")
+	historyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List the files changed by each edit")
+	return historyCmd
+}
+
+// summarizeDescription returns the first line of a transaction description, shortened for listing
+func summarizeDescription(description string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(description), "\n")
+	if runes := []rune(line); len(runes) > 60 {
+		line = string(runes[:57]) + "..."
+	}
+	if line == "" {
+		return "(no description)"
+	}
+	return line
+}
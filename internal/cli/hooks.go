@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/EricBriscoe/llm-tool/internal/git"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// reviewHooks lists the hooks that "hooks install" can write
+var reviewHooks = []string{"pre-commit", "pre-push"}
+
+// newHooksCmd creates the command that manages git hooks running llm-tool
+func newHooksCmd() *cobra.Command {
+	var repoPath string
+	var provider string
+	var model string
+	var failOn string
+
+	hooksCmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Manage git hooks that review changes before commit or push",
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install [hook...]",
+		Short: "Install review hooks (pre-commit and pre-push by default)",
+		Long: fmt.Sprintf(`Install git hooks that review staged changes before each commit
+(pre-commit) and the pushed commits before each push (pre-push). The commit or
+push is blocked when the review severity reaches --fail-on.
+
+Existing hooks are kept and run first. Set %s=1 to skip the review
+for a single command.`, git.HookBypassEnv),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := llm.ThresholdRank(failOn); err != nil {
+				return err
+			}
+
+			hooks := args
+			if len(hooks) == 0 {
+				hooks = reviewHooks
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate llm-tool executable: %w", err)
+			}
+
+			reviewArgs := fmt.Sprintf("--fail-on %s", failOn)
+			if provider != "" {
+				reviewArgs += fmt.Sprintf(" --provider %q", provider)
+			}
+			if model != "" {
+				reviewArgs += fmt.Sprintf(" --model %q", model)
+			}
+
+			for _, hook := range hooks {
+				var body string
+				switch hook {
+				case "pre-commit":
+					body = fmt.Sprintf("exec %q review --staged %s", executable, reviewArgs)
+				case "pre-push":
+					body = prePushHookBody(executable, reviewArgs)
+				default:
+					return fmt.Errorf("unsupported hook %q: use pre-commit or pre-push", hook)
+				}
+
+				hookPath, err := git.InstallHook(repoPath, hook, body)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Installed %s hook at %s\n", hook, hookPath)
+			}
+			return nil
+		},
+	}
+
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall [hook...]",
+		Short: "Remove llm-tool hooks and restore any hooks they chained",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hooks := args
+			if len(hooks) == 0 {
+				hooks = append(reviewHooks, "prepare-commit-msg")
+			}
+
+			for _, hook := range hooks {
+				removed, err := git.UninstallHook(repoPath, hook)
+				if err != nil {
+					return err
+				}
+				if removed {
+					fmt.Printf("Removed %s hook\n", hook)
+				}
+			}
+			return nil
+		},
+	}
+
+	hooksCmd.PersistentFlags().StringVarP(&repoPath, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	installCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider for the review (defaults to config)")
+	installCmd.Flags().StringVarP(&model, "model", "m", "", "Model for the review (defaults to config)")
+	installCmd.Flags().StringVar(&failOn, "fail-on", "high", "Block when the review severity reaches this level")
+
+	hooksCmd.AddCommand(installCmd)
+	hooksCmd.AddCommand(uninstallCmd)
+	return hooksCmd
+}
+
+// emptyTree is the hash of git's empty tree, the base for reviewing a root commit
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// prePushHookBody returns a pre-push hook that reviews each pushed range.
+// New branches are reviewed against their merge base with the remote's HEAD,
+// or if the remote has no HEAD, from the first commit not on the remote.
+func prePushHookBody(executable string, reviewArgs string) string {
+	return fmt.Sprintf(`printf '%%s\n' "$hook_input" | while read -r local_ref local_sha remote_ref remote_sha; do
+	# Skip empty lines and branch deletions
+	case "$local_sha" in
+	*[!0]*) ;;
+	*) continue ;;
+	esac
+
+	case "$remote_sha" in
+	*[!0]*) range="$remote_sha..$local_sha" ;;
+	*)
+		if ! base=$(git merge-base "$local_sha" "refs/remotes/$1/HEAD" 2>/dev/null); then
+			# Without the remote's HEAD, review the commits not on the remote,
+			# from the parent of the oldest (or the empty tree for a root commit)
+			first=$(git rev-list --reverse "$local_sha" --not --remotes="$1" | head -n 1)
+			[ -n "$first" ] || continue
+			base=$(git rev-parse --verify --quiet "$first^") || base=%s
+		fi
+		range="$base..$local_sha"
+		;;
+	esac
+
+	%q review --range "$range" %s </dev/null || exit 1
+done || exit 1`, emptyTree, executable, reviewArgs)
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/fatih/color"
+)
+
+// interactiveReview lets the user accept, reject or adjust each staged file,
+// or individual hunks of it, in the style of "git add -p". Rejected files are
+// unstaged; the files left in the staging area are the ones to apply. It
+// reports whether the user quit, in which case nothing should be applied.
+func interactiveReview(ctx context.Context, client llm.Client, stagingArea *fileutil.StagingArea, opts editOptions) (bool, error) {
+	reader := bufio.NewReader(os.Stdin)
+	files := append([]fileutil.StagedFile(nil), stagingArea.Files...)
+
+	acceptRest := false
+	for i, file := range files {
+		if acceptRest {
+			continue
+		}
+
+		for done := false; !done; {
+			// Re-read the file's state, which the editor or the model may have changed
+			current, ok := findStaged(stagingArea, file.OriginalPath)
+			if !ok {
+				break
+			}
+			original := originalContent(current)
+
+			fmt.Printf("\n(%d/%d) %s\n", i+1, len(files), current.OriginalPath)
+			printFileDiff(current, original, stagingArea.DiffOptions)
+
+			question := fmt.Sprintf("Apply changes to %s [y,n,a,d,s,e,r,q,?]? ", current.OriginalPath)
+			if current.IsDelete {
+				question = fmt.Sprintf("Delete %s [y,n,a,d,q,?]? ", current.OriginalPath)
+			}
+			response, err := prompt(reader, question)
+			if err != nil {
+				return false, err
+			}
+
+			response = strings.ToLower(response)
+			if current.IsDelete && (response == "s" || response == "e" || response == "r") {
+				fmt.Println("A deletion can only be applied or skipped as a whole.")
+				continue
+			}
+
+			switch response {
+			case "y", "":
+				done = true
+			case "n":
+				stagingArea.Unstage(current.OriginalPath)
+				done = true
+			case "a":
+				acceptRest = true
+				done = true
+			case "d":
+				for _, rest := range files[i:] {
+					stagingArea.Unstage(rest.OriginalPath)
+				}
+				return false, nil
+			case "s":
+				if err := reviewHunks(reader, stagingArea, current, original); err != nil {
+					return false, err
+				}
+				done = true
+			case "e":
+				if err := editStaged(stagingArea, current); err != nil {
+					return false, err
+				}
+			case "r":
+				followUp, err := prompt(reader, "Additional instructions: ")
+				if err != nil {
+					return false, err
+				}
+				if followUp == "" {
+					continue
+				}
+				if err := repromptStaged(ctx, client, stagingArea, current, opts, followUp); err != nil {
+					color.New(color.FgRed).Printf("Failed to re-prompt for %s: %v\n", current.OriginalPath, err)
+				}
+			case "q":
+				return true, nil
+			default:
+				printReviewHelp()
+			}
+		}
+	}
+	return false, nil
+}
+
+// reviewHunks asks about each hunk of a staged file and restages it with only
+// the accepted hunks. A file with no accepted hunks is unstaged.
+func reviewHunks(reader *bufio.Reader, stagingArea *fileutil.StagingArea, file fileutil.StagedFile, original string) error {
+	hunks := fileutil.DiffHunks(original, file.Content, stagingArea.DiffOptions.Context)
+	accepted := make([]bool, len(hunks))
+
+	decided := false
+	for i := 0; i < len(hunks) && !decided; i++ {
+		fmt.Println()
+		fileutil.WriteHunk(os.Stdout, hunks[i])
+
+		response, err := prompt(reader, fmt.Sprintf("(%d/%d) Apply this hunk [y,n,a,d,?]? ", i+1, len(hunks)))
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(response) {
+		case "y", "":
+			accepted[i] = true
+		case "n":
+		case "a":
+			for j := i; j < len(hunks); j++ {
+				accepted[j] = true
+			}
+			decided = true
+		case "d":
+			decided = true
+		default:
+			fmt.Println("y - apply this hunk\nn - skip this hunk\na - apply this and all later hunks\nd - skip this and all later hunks")
+			i--
+		}
+	}
+
+	content := fileutil.ApplyHunks(original, hunks, accepted)
+	if content == original {
+		stagingArea.Unstage(file.OriginalPath)
+		return nil
+	}
+	if _, err := stagingArea.StageFile(file.OriginalPath, content, file.IsNew); err != nil {
+		return fmt.Errorf("failed to stage file %s: %w", file.OriginalPath, err)
+	}
+	return nil
+}
+
+// editStaged opens the staged copy of a file in the user's editor and
+// restages whatever they save
+func editStaged(stagingArea *fileutil.StagingArea, file fileutil.StagedFile) error {
+	if err := openInEditor(file.StagedPath); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(file.StagedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read edited file: %w", err)
+	}
+	if _, err := stagingArea.StageFile(file.OriginalPath, string(edited), file.IsNew); err != nil {
+		return fmt.Errorf("failed to stage file %s: %w", file.OriginalPath, err)
+	}
+	return nil
+}
+
+// repromptStaged asks the model for further changes to a single staged file
+func repromptStaged(ctx context.Context, client llm.Client, stagingArea *fileutil.StagingArea, file fileutil.StagedFile, opts editOptions, followUp string) error {
+	fmt.Printf("Re-prompting for %s...\n", file.OriginalPath)
+
+	followOpts := opts
+	followOpts.instructions = llm.BuildFollowUpInstructions(opts.instructions, followUp)
+	// The staged content is the starting point, so it may legitimately shrink
+	followOpts.allowShrink = true
+
+	edited, err := editContent(ctx, client, file.OriginalPath, file.Content, followOpts)
+	if err != nil {
+		return err
+	}
+	if _, err := stagingArea.StageFile(file.OriginalPath, edited, file.IsNew); err != nil {
+		return fmt.Errorf("failed to stage file %s: %w", file.OriginalPath, err)
+	}
+	return nil
+}
+
+// findStaged returns the staged file with the given original path
+func findStaged(stagingArea *fileutil.StagingArea, originalPath string) (fileutil.StagedFile, bool) {
+	for _, file := range stagingArea.Files {
+		if file.OriginalPath == originalPath {
+			return file, true
+		}
+	}
+	return fileutil.StagedFile{}, false
+}
+
+// originalContent returns the current on-disk content of a staged file, or
+// "" for a new file
+func originalContent(file fileutil.StagedFile) string {
+	if file.IsNew {
+		return ""
+	}
+	content, err := os.ReadFile(file.OriginalPath)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// printFileDiff prints the diff of a staged file and its problems
+func printFileDiff(file fileutil.StagedFile, original string, diffOptions fileutil.DiffOptions) {
+	oldName, newName := file.OriginalPath, file.OriginalPath
+	switch {
+	case file.IsNew:
+		oldName = "/dev/null"
+	case file.IsDelete:
+		newName = "/dev/null"
+	}
+	fileutil.WriteDiff(os.Stdout, oldName, newName, original, file.Content, diffOptions)
+	printProblems(file.Problems)
+}
+
+// printProblems prints the validation failures of a staged file
+func printProblems(problems []string) {
+	red := color.New(color.FgRed)
+	for _, problem := range problems {
+		red.Printf("  ✗ %s\n", strings.ReplaceAll(strings.TrimRight(problem, "\n"), "\n", "\n    "))
+	}
+}
+
+// prompt prints question and returns the trimmed answer
+func prompt(reader *bufio.Reader, question string) (string, error) {
+	fmt.Print(question)
+	response, err := reader.ReadString('\n')
+	if err != nil && response == "" {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// printReviewHelp explains the choices offered for each file
+func printReviewHelp() {
+	fmt.Println(`y - apply this file
+n - don't apply this file
+a - apply this file and all remaining files
+d - don't apply this file or any remaining files
+s - choose individual hunks of this file
+e - edit the staged file in $EDITOR
+r - re-prompt the model for this file with additional instructions
+q - quit without applying anything`)
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+)
+
+// maxPackageContextBytes limits how much of the other files in the target
+// files' packages is sent along with a multi-file edit
+const maxPackageContextBytes = 200_000
+
+// editMultiFile edits files together in a single request, sending the files
+// given with --context and the rest of each target's package for reference,
+// and asking for the new files in create. New files must be inside baseDir.
+// It returns a result for each file the model changed, created or deleted.
+// The edit is all or nothing: if any file fails, every target fails with the
+// same error.
+func editMultiFile(ctx context.Context, client llm.Client, baseDir string, files []string, contextFiles []string, create []string, opts editOptions) []editResult {
+	results, err := runMultiFileEdit(ctx, client, baseDir, files, contextFiles, create, opts)
+	if err != nil {
+		results = nil
+		for _, filename := range append(append([]string(nil), files...), create...) {
+			results = append(results, editResult{filename: filename, err: err})
+		}
+	}
+	return results
+}
+
+// runMultiFileEdit sends the files to the model and returns its changes
+func runMultiFileEdit(ctx context.Context, client llm.Client, baseDir string, files []string, contextFiles []string, create []string, opts editOptions) ([]editResult, error) {
+	names := make(map[string]string) // Name shown to the model -> path
+	originals := make(map[string]string)
+	load := func(path string) (llm.SourceFile, error) {
+		content, err := fileutil.ReadFileContent(path)
+		if err != nil {
+			return llm.SourceFile{}, err
+		}
+		name := displayName(path)
+		names[name] = path
+		originals[path] = content
+		return llm.SourceFile{Name: name, Content: content}, nil
+	}
+
+	req := llm.MultiFileRequest{Instructions: opts.instructions}
+	for _, path := range create {
+		req.Create = append(req.Create, displayName(path))
+	}
+	sent := make(map[string]bool)
+	for _, path := range files {
+		file, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		req.Files = append(req.Files, file)
+		sent[canonical(path)] = true
+	}
+	for _, path := range contextFiles {
+		if sent[canonical(path)] {
+			continue
+		}
+		file, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		req.Related = append(req.Related, file)
+		sent[canonical(path)] = true
+	}
+
+	budget := maxPackageContextBytes
+	skipped := 0
+	for _, path := range packageFiles(append(append([]string(nil), files...), create...)) {
+		if sent[canonical(path)] {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || int(info.Size()) > budget {
+			skipped++
+			continue
+		}
+		file, err := load(path)
+		if err != nil {
+			skipped++
+			continue
+		}
+		budget -= len(file.Content)
+		req.Related = append(req.Related, file)
+		sent[canonical(path)] = true
+	}
+	if skipped > 0 {
+		fmt.Printf("Left out %d files of the same packages to stay within the context limit\n", skipped)
+	}
+
+	switch opts.mode {
+	case llm.EditModeSearchReplace:
+		req.Mode = llm.EditModeSearchReplace
+	case editModeAuto:
+		for _, file := range append(append([]llm.SourceFile(nil), req.Files...), req.Related...) {
+			if strings.Count(file.Content, "\n") >= autoPatchLines {
+				req.Mode = llm.EditModeSearchReplace
+			}
+		}
+	}
+
+	fmt.Printf("Editing %d files together, with %d related files for reference...\n", len(req.Files), len(req.Related))
+	changes, err := llm.EditFiles(ctx, client, req, opts.model)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("the model didn't change any files")
+	}
+
+	var results []editResult
+	var problems []string
+	for _, change := range changes {
+		path, sent := names[change.Name]
+		if !sent {
+			path = filepath.FromSlash(change.Name)
+			if _, inside := fileutil.RelativePath(baseDir, path); !inside {
+				problems = append(problems, fmt.Sprintf("%s is outside %s", change.Name, baseDir))
+				continue
+			}
+			if fileExists(path) {
+				problems = append(problems, fmt.Sprintf("%s already exists but wasn't sent to the model", change.Name))
+				continue
+			}
+		}
+
+		result := editResult{filename: path, original: originals[path], content: change.Content, deleted: change.IsDelete}
+		if !change.IsNew && !change.IsDelete {
+			lost, warnings := llm.DetectLostContent(path, result.original, change.Content, opts.lostContentChecks())
+			if len(lost) > 0 {
+				problems = append(problems, fmt.Sprintf("%s appears to be missing content:\n    %s", change.Name, strings.Join(lost, "\n    ")))
+			}
+			for _, warning := range warnings {
+				opts.note("  Warning: %s: %s (use --strict-brackets to fail instead)\n", change.Name, warning)
+			}
+		}
+		results = append(results, result)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("not staging any files:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].filename < results[j].filename
+	})
+	return results, nil
+}
+
+// packageFiles returns the files in the directories of files that have the
+// same extension as one of them, such as the rest of a Go package, in a
+// stable order
+func packageFiles(files []string) []string {
+	exts := make(map[string]map[string]bool) // Directory -> extensions
+	for _, path := range files {
+		dir := filepath.Dir(path)
+		if exts[dir] == nil {
+			exts[dir] = make(map[string]bool)
+		}
+		if ext := filepath.Ext(path); ext != "" {
+			exts[dir][ext] = true
+		}
+	}
+
+	var related []string
+	for _, dir := range sortedKeys(exts) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !exts[dir][filepath.Ext(name)] {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if binary, err := fileutil.IsBinaryFile(path); err != nil || binary {
+				continue
+			}
+			related = append(related, path)
+		}
+	}
+	return related
+}
+
+// displayName returns path relative to the working directory when it is
+// inside it, for showing to the model
+func displayName(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, ok := fileutil.RelativePath(wd, path); ok {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// canonical returns an absolute, cleaned form of path for comparisons
+func canonical(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
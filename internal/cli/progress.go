@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// progress reports the state of files processed concurrently on stderr. On a
+// terminal a status line showing the files in flight is kept below the
+// per-file results; otherwise only the results are printed.
+type progress struct {
+	mu      sync.Mutex
+	live    bool
+	total   int
+	done    int
+	failed  int
+	running []string
+}
+
+// newProgress creates a progress display for total files
+func newProgress(total int) *progress {
+	info, err := os.Stderr.Stat()
+	live := err == nil && (info.Mode()&os.ModeCharDevice) != 0 && os.Getenv("TERM") != "dumb"
+	return &progress{live: live, total: total}
+}
+
+// start marks name as in flight
+func (p *progress) start(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.running = append(p.running, name)
+	p.redraw()
+}
+
+// finish marks name as done, printing its result
+func (p *progress) finish(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, running := range p.running {
+		if running == name {
+			p.running = append(p.running[:i], p.running[i+1:]...)
+			break
+		}
+	}
+	p.done++
+
+	p.clear()
+	if err != nil {
+		p.failed++
+		color.New(color.FgRed).Fprintf(os.Stderr, "[%d/%d] ✗ %s: %v\n", p.done, p.total, name, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "[%d/%d] ✓ %s\n", p.done, p.total, name)
+	}
+	p.redraw()
+}
+
+// notef prints a message about a file without disturbing the status line
+func (p *progress) notef(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	fmt.Fprintf(os.Stderr, format, args...)
+	p.redraw()
+}
+
+// stop removes the status line
+func (p *progress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	p.running = nil
+}
+
+// clear erases the status line
+func (p *progress) clear() {
+	if p.live {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+// redraw prints the status line
+func (p *progress) redraw() {
+	if !p.live || len(p.running) == 0 {
+		return
+	}
+
+	status := fmt.Sprintf("[%d/%d] editing %s", p.done, p.total, strings.Join(p.running, ", "))
+	if p.failed > 0 {
+		status += fmt.Sprintf(" (%d failed)", p.failed)
+	}
+	// Keep the status on one line so that it can be erased
+	if runes := []rune(status); len(runes) > 100 {
+		status = string(runes[:97]) + "..."
+	}
+	fmt.Fprint(os.Stderr, status)
+}
@@ -1,14 +1,11 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/EricBriscoe/llm-tool/internal/config"
-	"github.com/EricBriscoe/llm-tool/internal/fileutil"
 	"github.com/EricBriscoe/llm-tool/internal/git"
 	"github.com/EricBriscoe/llm-tool/internal/llm"
 	"github.com/spf13/cobra"
@@ -22,8 +19,15 @@ func NewRootCmd() *cobra.Command {
 	var token string
 	var endpoint string
 	var datasource string
-	var applyChanges bool
-	var outputDir string
+	var guidelinesFile string
+	var noGuidelines bool
+	var reviewContext string
+	var contextBudget int
+	var stagedOnly bool
+	var reviewRange string
+	var failOn string
+	var schemaFile string
+	var schemaRetries int
 
 	rootCmd := &cobra.Command{
 		Use:   "llm-tool",
@@ -119,7 +123,16 @@ func NewRootCmd() *cobra.Command {
 	askCmd := &cobra.Command{
 		Use:   "ask [prompt]",
 		Short: "Ask a question to an LLM",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Ask a question to an LLM and stream the answer.
+
+With --schema, the answer is JSON matching the given JSON schema instead. The
+provider's structured output mode is used where it has one, and the answer is
+validated locally either way. An answer that doesn't match is sent back to the
+model with the problems found, up to --retries times, and the command fails if
+it still doesn't match. Only the JSON is printed, so it can be piped to other
+tools.`,
+		Example: `  llm-tool ask --schema person.json "Extract the author of this text: ..."`,
+		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			prompt := args[0]
 			
@@ -142,6 +155,10 @@ func NewRootCmd() *cobra.Command {
 				return err
 			}
 			
+			if schemaFile != "" {
+				return askStructured(cmd.Context(), client, prompt, schemaFile, model, schemaRetries)
+			}
+			
 			return client.StreamResponse(cmd.Context(), prompt, model)
 		},
 	}
@@ -149,10 +166,14 @@ func NewRootCmd() *cobra.Command {
 	reviewCmd := &cobra.Command{
 		Use:   "review [branch-name]",
 		Short: "Review code diff between current branch and specified branch",
-		Args:  cobra.ExactArgs(1),
+		Long: `Review the code diff between the current branch and the specified branch,
+the changes staged in the index (--staged), or a commit range (--range).
+
+With --fail-on, the command exits with an error when the reviewer rates the
+changes at or above the given severity (low, medium, high or critical), or
+doesn't rate them at all, which makes it usable as a git hook.`,
+		Args: cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			branchName := args[0]
-			
 			cfg, err := config.Load()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
@@ -162,159 +183,84 @@ func NewRootCmd() *cobra.Command {
 				provider = cfg.DefaultProvider
 			}
 			
-			diff, err := git.GetDiff(branchName, repoPath)
+			var diff, postRef, target string
+			switch {
+			case stagedOnly:
+				target = "the index"
+				postRef = ":"
+				diff, err = git.GetStagedDiff(repoPath)
+			case reviewRange != "":
+				target = reviewRange
+				postRef = rangeEnd(reviewRange)
+				diff, err = git.GetRangeDiff(reviewRange, repoPath)
+			case len(args) == 1:
+				target = args[0]
+				diff, postRef, err = git.GetDiffWithRef(args[0], repoPath)
+			default:
+				return fmt.Errorf("specify a branch name, --staged or --range")
+			}
 			if err != nil {
 				return fmt.Errorf("failed to get diff: %w", err)
 			}
 			
-			if diff == "" {
-				return fmt.Errorf("no diff found between current branch and %s", branchName)
-			}
-			
-			client, err := llm.NewClient(provider, cfg)
-			if err != nil {
-				return err
+			if strings.TrimSpace(diff) == "" {
+				if stagedOnly || reviewRange != "" {
+					fmt.Printf("No changes to review in %s\n", target)
+					return nil
+				}
+				return fmt.Errorf("no diff found between current branch and %s", target)
 			}
 			
-			return client.ReviewCodeDiff(cmd.Context(), diff, model)
-		},
-	}
-
-	editCmd := &cobra.Command{
-		Use:   "edit [flags] [instructions] [files...]",
-		Short: "Edit or refactor files using an LLM",
-		Long: `Edit or refactor files using an LLM based on instructions.
-Files can be provided as arguments or piped through stdin.
-Changes are staged for review before being applied.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			// Get the refactoring instructions from stdin if no files are specified
-			// or from the first arg if there are files specified
-			var instructions string
-			var files []string
-
-			// Check if we're receiving from a pipe
-			info, _ := os.Stdin.Stat()
-			isPipe := (info.Mode() & os.ModeCharDevice) == 0
-
-			if len(args) == 0 {
-				if !isPipe {
-					return fmt.Errorf("no files specified and no input from pipe")
-				}
-				// Read from stdin, treat as a single file "-"
-				files = []string{"-"}
-				
-				// Prompt for instructions interactively
-				reader := bufio.NewReader(os.Stdin)
-				fmt.Print("Enter refactoring instructions: ")
-				instructionsBytes, err := reader.ReadBytes('\n')
+			threshold := 0
+			if failOn != "" {
+				threshold, err = llm.ThresholdRank(failOn)
 				if err != nil {
-					return fmt.Errorf("failed to read instructions: %w", err)
-				}
-				instructions = strings.TrimSpace(string(instructionsBytes))
-				
-				// Re-open stdin for file content
-				// Note: This is a limitation - we can't easily get both instructions and file content
-				// from stdin in the same session. In practice, users would provide instructions as args.
-				fmt.Println("Now enter the file content to be refactored (Ctrl+D when finished):")
-			} else if isPipe {
-				// Pipe exists but we also have args, first arg is instructions
-				instructions = args[0]
-				files = []string{"-"} // Read file content from stdin
-			} else {
-				// No pipe, first arg is instructions, rest are files
-				if len(args) < 2 {
-					return fmt.Errorf("please provide both instructions and at least one file")
+					return err
 				}
-				instructions = args[0]
-				files = args[1:]
 			}
 			
-			if instructions == "" {
-				return fmt.Errorf("refactoring instructions cannot be empty")
-			}
-
-			cfg, err := config.Load()
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
-
-			if provider == "" {
-				provider = cfg.DefaultProvider
-			}
-
-			client, err := llm.NewClient(provider, cfg)
+			guidelines, err := loadReviewGuidelines(repoPath, guidelinesFile, noGuidelines)
 			if err != nil {
 				return err
 			}
-
-			// Create staging area for processed files
-			stagingArea, err := fileutil.NewStagingArea()
-			if err != nil {
-				return err
+			
+			review := llm.ReviewRequest{
+				Diff:            diff,
+				Guidelines:      llm.FormatReviewGuidelines(guidelines, git.ChangedFiles(diff)),
+				RequireSeverity: failOn != "",
 			}
-			defer stagingArea.Cleanup()
-
-			// Process each file
-			fmt.Printf("Processing %d files with the following instructions:\n%s\n\n", len(files), instructions)
 			
-			for _, filename := range files {
-				fmt.Printf("Processing file: %s\n", filename)
-				
-				// Read file content
-				content, err := fileutil.ReadFileContent(filename)
+			if reviewContext != "" {
+				review.Context, err = git.BuildContext(diff, reviewContext, repoPath, postRef, contextBudget)
 				if err != nil {
-					return err
-				}
-				
-				// Process with LLM
-				refactoredContent, err := client.RefactorFile(cmd.Context(), filename, content, instructions, model)
-				if err != nil {
-					return fmt.Errorf("failed to refactor %s: %w", filename, err)
-				}
-				
-				// Stage the result
-				isNew := !fileExists(filename) || filename == "-"
-				outputFilename := filename
-				if outputDir != "" {
-					// If output directory is specified, write there instead
-					outputFilename = filepath.Join(outputDir, filepath.Base(filename))
+					return fmt.Errorf("failed to build review context: %w", err)
 				}
-				if isNew && outputFilename == "-" {
-					outputFilename = "output.txt"
-				}
-				
-				_, err = stagingArea.StageFile(outputFilename, refactoredContent, isNew)
-				if err != nil {
-					return fmt.Errorf("failed to stage file %s: %w", outputFilename, err)
-				}
-				
-				fmt.Printf("✓ Processed %s\n", filename)
 			}
 			
-			// Show diffs and prompt for confirmation
-			fmt.Println("\nReview of changes:")
-			if err := stagingArea.ShowDiff(); err != nil {
-				return fmt.Errorf("failed to show diffs: %w", err)
+			client, err := llm.NewClient(provider, cfg)
+			if err != nil {
+				return err
 			}
 			
-			if !applyChanges {
-				// Ask for confirmation
-				fmt.Print("\nApply these changes? [y/N] ")
-				var response string
-				fmt.Scanln(&response)
-				
-				if response != "y" && response != "Y" {
-					fmt.Println("Changes not applied.")
-					return nil
-				}
+			result, err := client.ReviewCodeDiff(cmd.Context(), review, model)
+			if err != nil || failOn == "" {
+				return err
 			}
 			
-			// Apply changes
-			if err := stagingArea.ApplyChanges(); err != nil {
-				return fmt.Errorf("failed to apply changes: %w", err)
+			// Don't repeat the error and usage text below the review
+			cmd.SilenceUsage = true
+			
+			// Fail closed: a review without a severity can't pass the gate
+			severity, ok := llm.ParseReviewSeverity(result)
+			if !ok {
+				return fmt.Errorf("the review did not report a severity, so it can't be checked against --fail-on %s", failOn)
 			}
 			
-			fmt.Println("All changes applied successfully.")
+			rank, _ := llm.SeverityRank(severity)
+			if rank >= threshold {
+				return fmt.Errorf("review found %s severity issues (threshold: %s)", severity, failOn)
+			}
+			fmt.Printf("Review severity %s is below the %s threshold\n", severity, failOn)
 			return nil
 		},
 	}
@@ -322,11 +268,6 @@ Changes are staged for review before being applied.`,
 	// Add flags to commands
 	clearHistoryCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
 	
-	editCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
-	editCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
-	editCmd.Flags().BoolVarP(&applyChanges, "yes", "y", false, "Apply changes without confirmation")
-	editCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory for refactored files")
-	editCmd.Flags().StringVarP(&datasource, "datasource", "d", "", "Datasource to use (CBOE only)")
 
 	setupTokenCmd.Flags().StringVarP(&email, "email", "e", "", "Email for CBOE authentication")
 	setupTokenCmd.Flags().StringVarP(&token, "token", "t", "", "Token for CBOE authentication")
@@ -335,10 +276,19 @@ Changes are staged for review before being applied.`,
 	askCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
 	askCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
 	askCmd.Flags().StringVarP(&datasource, "datasource", "d", "", "Datasource to use (CBOE only)")
+	askCmd.Flags().StringVar(&schemaFile, "schema", "", "Answer with JSON matching the JSON schema in this file")
+	askCmd.Flags().IntVar(&schemaRetries, "retries", 2, "Times to retry when the answer doesn't match --schema")
 	
 	reviewCmd.Flags().StringVarP(&provider, "provider", "p", "", "LLM provider (openai, cboe, gemini)")
 	reviewCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (defaults to config)")
 	reviewCmd.Flags().StringVarP(&repoPath, "repo", "r", "", "Path to git repository (defaults to current directory)")
+	reviewCmd.Flags().StringVar(&guidelinesFile, "guidelines", "", "Review guidelines file (defaults to .llm-tool/review.md)")
+	reviewCmd.Flags().BoolVar(&noGuidelines, "no-guidelines", false, "Ignore project review guidelines and rules")
+	reviewCmd.Flags().StringVar(&reviewContext, "context", "", "Include surrounding code: full, function, or a number of lines")
+	reviewCmd.Flags().IntVar(&contextBudget, "context-budget", 8000, "Approximate token budget for --context")
+	reviewCmd.Flags().BoolVar(&stagedOnly, "staged", false, "Review changes staged in the index")
+	reviewCmd.Flags().StringVar(&reviewRange, "range", "", "Review a commit range, e.g. origin/main..HEAD")
+	reviewCmd.Flags().StringVar(&failOn, "fail-on", "", "Exit with an error if the review severity reaches this level")
 
 	// Add commands to root command
 	configCmd.AddCommand(configPathCmd)
@@ -346,20 +296,57 @@ Changes are staged for review before being applied.`,
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(askCmd)
 	rootCmd.AddCommand(reviewCmd)
-	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(newEditCmd())
+	rootCmd.AddCommand(newGenerateCmd())
+	rootCmd.AddCommand(newAgentCmd())
 	rootCmd.AddCommand(clearHistoryCmd)
+	rootCmd.AddCommand(newCommitCmd())
+	rootCmd.AddCommand(newPRDescriptionCmd())
+	rootCmd.AddCommand(newChangelogCmd())
+	rootCmd.AddCommand(newHooksCmd())
+	rootCmd.AddCommand(newUndoCmd())
+	rootCmd.AddCommand(newHistoryCmd())
+	rootCmd.AddCommand(newStagedCmd())
 	
 	return rootCmd
 }
 
-// fileExists checks if a file exists and is not a directory
-func fileExists(filename string) bool {
-	if filename == "-" {
-		return false
+// loadReviewGuidelines loads the project review guidelines found above repoPath.
+// An explicit guidelines file replaces the project's review.md but keeps its rules.
+func loadReviewGuidelines(repoPath string, guidelinesFile string, disabled bool) (*config.ReviewGuidelines, error) {
+	if disabled {
+		return nil, nil
+	}
+
+	guidelines, err := config.LoadReviewGuidelines(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review guidelines: %w", err)
+	}
+
+	if guidelinesFile != "" {
+		data, err := os.ReadFile(guidelinesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guidelines file: %w", err)
+		}
+		if guidelines == nil {
+			guidelines = &config.ReviewGuidelines{}
+		}
+		guidelines.Guidelines = string(data)
+	}
+
+	return guidelines, nil
+}
+
+// rangeEnd returns the revision at the end of a "from..to" or "from...to"
+// range. A range without ".." compares against the working tree, which is
+// reported as an empty revision.
+func rangeEnd(revRange string) string {
+	idx := strings.LastIndex(revRange, "..")
+	if idx < 0 {
+		return ""
 	}
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
+	if end := revRange[idx+2:]; end != "" {
+		return end
 	}
-	return !info.IsDir()
+	return "HEAD"
 }
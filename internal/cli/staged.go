@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"github.com/spf13/cobra"
+)
+
+// newStagedCmd creates the command that manages edits saved with --stage-only
+func newStagedCmd() *cobra.Command {
+	stagedCmd := &cobra.Command{
+		Use:   "staged",
+		Short: "Review and apply edits saved with edit --stage-only",
+		Long: `Manage staging sessions saved by "llm-tool edit --stage-only".
+
+Each session keeps the staged files under
+~/.config/llm-tool/staging/<id>/files, mirroring their paths in the
+repository. You can edit those copies, or delete one to drop that file, before
+applying the session.`,
+	}
+
+	stagedCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List saved staging sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessions, err := fileutil.ListSessions(config.GetStagingDir())
+			if err != nil {
+				return err
+			}
+			if len(sessions) == 0 {
+				fmt.Println("No staged edits.")
+				return nil
+			}
+
+			for _, session := range sessions {
+				fmt.Printf("%s  %s  %d files  %s\n", session.ID, session.Time.Format("2006-01-02 15:04"), len(session.Files), summarizeDescription(session.Description))
+			}
+			return nil
+		},
+	})
+
+	stagedCmd.AddCommand(&cobra.Command{
+		Use:   "show <id>",
+		Short: "Show the changes in a staging session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, stagingArea, err := loadStaged(cmd, args[0])
+			if err != nil {
+				return err
+			}
+			return stagingArea.ShowDiff()
+		},
+	})
+
+	var assumeYes bool
+	applyCmd := &cobra.Command{
+		Use:   "apply <id>",
+		Short: "Apply a staging session and delete it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, stagingArea, err := loadStaged(cmd, args[0])
+			if err != nil {
+				return err
+			}
+			if len(stagingArea.Files) == 0 {
+				return fmt.Errorf("session %s has no staged files", session.ID)
+			}
+
+			fmt.Println("Review of changes:")
+			if err := stagingArea.ShowDiff(); err != nil {
+				return fmt.Errorf("failed to show diffs: %w", err)
+			}
+
+			if assumeYes && stagingArea.HasProblems() {
+				return fmt.Errorf("not applying changes because verification failed")
+			}
+			if !assumeYes {
+				fmt.Print("\nApply these changes? [y/N] ")
+				var response string
+				fmt.Scanln(&response)
+				if response != "y" && response != "Y" {
+					fmt.Println("Changes not applied.")
+					return nil
+				}
+			}
+
+			proceed, err := resolveConflicts(stagingArea, assumeYes)
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				fmt.Println("Changes not applied.")
+				return nil
+			}
+
+			stagingArea.HistoryDir = config.GetHistoryDir()
+			if err := stagingArea.ApplyChanges(); err != nil {
+				return fmt.Errorf("failed to apply changes: %w", err)
+			}
+			return fileutil.DiscardSession(config.GetStagingDir(), session.ID)
+		},
+	}
+	applyCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Apply changes without confirmation")
+	stagedCmd.AddCommand(applyCmd)
+
+	stagedCmd.AddCommand(&cobra.Command{
+		Use:   "discard <id>",
+		Short: "Delete a staging session without applying it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := fileutil.DiscardSession(config.GetStagingDir(), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Discarded session %s\n", args[0])
+			return nil
+		},
+	})
+
+	return stagedCmd
+}
+
+// loadStaged loads a staging session with its files as they are now on disk
+// and checks them again, since they may have been edited by hand
+func loadStaged(cmd *cobra.Command, id string) (*fileutil.Session, *fileutil.StagingArea, error) {
+	stagingDir := config.GetStagingDir()
+	session, err := fileutil.LoadSession(stagingDir, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stagingArea, err := session.StagingArea(stagingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := checkStaged(cmd.Context(), stagingArea, stagedChecks{}); err != nil {
+		return nil, nil, err
+	}
+	return session, stagingArea, nil
+}
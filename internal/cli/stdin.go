@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+	"github.com/EricBriscoe/llm-tool/internal/verify"
+)
+
+// editInstructions returns the edit's instructions and the remaining
+// arguments, which are its targets. The instructions come from -i or
+// --instructions-file when either is given, or from the first argument.
+func editInstructions(args []string, instructions string, instructionsFile string) (string, []string, error) {
+	if instructions != "" && instructionsFile != "" {
+		return "", nil, fmt.Errorf("-i and --instructions-file cannot be used together")
+	}
+
+	if instructionsFile != "" {
+		var data []byte
+		var err error
+		if instructionsFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(instructionsFile)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read instructions: %w", err)
+		}
+		return strings.TrimSpace(string(data)), args, nil
+	}
+	if instructions != "" {
+		return strings.TrimSpace(instructions), args, nil
+	}
+
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("please provide the instructions as the first argument or with -i")
+	}
+	return strings.TrimSpace(args[0]), args[1:], nil
+}
+
+// editStdin edits the content piped to stdin and writes the result to stdout,
+// so that edit can be used as a filter. Progress notes go to stderr and
+// nothing is written to stdout unless the edit succeeds. name is shown to the
+// model in place of a file name; Go content is gofmt'd.
+func editStdin(ctx context.Context, client llm.Client, name string, opts editOptions) error {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read from stdin: %w", err)
+	}
+	content := string(data)
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("no content on stdin to edit")
+	}
+	if name == "" {
+		name = "-"
+	}
+
+	opts.notef = func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+	edited, err := editContent(ctx, client, name, content, opts)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Ext(name) == ".go" {
+		edited, err = verify.FormatGo(name, edited)
+		if err != nil {
+			return fmt.Errorf("edited content is not valid Go: %w", err)
+		}
+	}
+
+	if _, err := os.Stdout.WriteString(edited); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/EricBriscoe/llm-tool/internal/llm"
+)
+
+// askStructured asks for an answer to prompt as JSON matching the schema in
+// schemaFile and prints it indented on stdout
+func askStructured(ctx context.Context, client llm.Client, prompt string, schemaFile string, model string, retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("--retries can't be negative")
+	}
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	if err := llm.CheckSchema(schema); err != nil {
+		return fmt.Errorf("%s: %w", schemaFile, err)
+	}
+
+	answer, err := llm.CompleteStructured(ctx, client, prompt, schema, model, retries+1)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, answer, "", "  "); err != nil {
+		return fmt.Errorf("failed to format JSON: %w", err)
+	}
+	out.WriteByte('\n')
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
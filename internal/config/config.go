@@ -14,34 +14,76 @@ type Config struct {
 	OpenAI          OpenAIConfig `yaml:"openai"`
 	CBOE            CBOEConfig   `yaml:"cboe"`
 	Gemini          GeminiConfig `yaml:"gemini"`
+	Commit          CommitConfig `yaml:"commit"`
+	Agent           AgentConfig  `yaml:"agent"`
 }
 
 // OpenAIConfig stores OpenAI-specific configuration
 type OpenAIConfig struct {
-	APIKey string `yaml:"apiKey"`
-	Model  string `yaml:"model"`
+	APIKey         string `yaml:"apiKey"`
+	Model          string `yaml:"model"`
+	MaxConcurrency int    `yaml:"maxConcurrency"` // Maximum parallel requests
 }
 
 // CBOEConfig stores CBOE-specific configuration
 type CBOEConfig struct {
-	Email      string `yaml:"email"`      // Email for CBOE authentication
-	Token      string `yaml:"token"`      // Token for CBOE authentication
-	Endpoint   string `yaml:"endpoint"`   // API endpoint
-	Model      string `yaml:"model"`      // Model to use
-	Datasource string `yaml:"datasource"` // Default datasource to use if any
+	Email          string `yaml:"email"`          // Email for CBOE authentication
+	Token          string `yaml:"token"`          // Token for CBOE authentication
+	Endpoint       string `yaml:"endpoint"`       // API endpoint
+	Model          string `yaml:"model"`          // Model to use
+	Datasource     string `yaml:"datasource"`     // Default datasource to use if any
+	MaxConcurrency int    `yaml:"maxConcurrency"` // Maximum parallel requests
 }
 
 // GeminiConfig stores Google Gemini-specific configuration
 type GeminiConfig struct {
-	APIKey string `yaml:"apiKey"` // API key for Gemini authentication
-	Model  string `yaml:"model"`  // Model to use
+	APIKey         string `yaml:"apiKey"`         // API key for Gemini authentication
+	Model          string `yaml:"model"`          // Model to use
+	MaxConcurrency int    `yaml:"maxConcurrency"` // Maximum parallel requests
 }
 
-// GetConfigPath returns the path to the config file
-func GetConfigPath() string {
+// CommitConfig stores settings for generated commit messages
+type CommitConfig struct {
+	Style            string `yaml:"style"`            // "conventional" or "plain"
+	MaxSubjectLength int    `yaml:"maxSubjectLength"` // Maximum length of the subject line
+	BodyWrap         int    `yaml:"bodyWrap"`         // Column at which to wrap the body
+}
+
+// AgentConfig stores settings for the agent command
+type AgentConfig struct {
+	MaxSteps int `yaml:"maxSteps"` // Maximum number of model turns per task
+
+	// Commands lists the commands the agent may run, such as "go test ./...".
+	// The agent can't run commands when the list is empty.
+	Commands       []AgentCommand `yaml:"commands"`
+	CommandTimeout int            `yaml:"commandTimeout"` // Seconds before a command is stopped
+}
+
+// AgentCommand is a command the agent may run. It must be run exactly as
+// listed unless AllowArgs is set, since extra arguments such as
+// "go test -exec=..." can run anything.
+type AgentCommand struct {
+	Command   string `yaml:"command"`
+	AllowArgs bool   `yaml:"allowArgs"` // Let the agent add arguments after Command
+}
+
+// UnmarshalYAML accepts a plain string as a command without extra arguments
+func (c *AgentCommand) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		c.Command = value.Value
+		c.AllowArgs = false
+		return nil
+	}
+	type plain AgentCommand
+	return value.Decode((*plain)(c))
+}
+
+// GetConfigDir returns the directory holding the config file and other
+// persistent state, creating it if needed
+func GetConfigDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return ".llm-tool.yaml" // Fallback to current directory
+		return "." // Fallback to current directory
 	}
 	
 	configDir := filepath.Join(homeDir, ".config", "llm-tool")
@@ -49,7 +91,26 @@ func GetConfigPath() string {
 		os.MkdirAll(configDir, 0755)
 	}
 	
-	return filepath.Join(configDir, "config.yaml")
+	return configDir
+}
+
+// GetHistoryDir returns the directory where applied edits are recorded for undo
+func GetHistoryDir() string {
+	return filepath.Join(GetConfigDir(), "transactions")
+}
+
+// GetStagingDir returns the directory where staged edits are saved for later review
+func GetStagingDir() string {
+	return filepath.Join(GetConfigDir(), "staging")
+}
+
+// GetConfigPath returns the path to the config file
+func GetConfigPath() string {
+	if _, err := os.UserHomeDir(); err != nil {
+		return ".llm-tool.yaml" // Fallback to current directory
+	}
+	
+	return filepath.Join(GetConfigDir(), "config.yaml")
 }
 
 // Load loads configuration from file
@@ -60,14 +121,26 @@ func Load() (*Config, error) {
 	config := &Config{
 		DefaultProvider: "openai",
 		OpenAI: OpenAIConfig{
-			Model: "gpt-4o-mini",
+			Model:          "gpt-4o-mini",
+			MaxConcurrency: 8,
 		},
 		CBOE: CBOEConfig{
-			Endpoint: "https://api.cboe.com/llm/v1",
-			Model:    "default",
+			Endpoint:       "https://api.cboe.com/llm/v1",
+			Model:          "default",
+			MaxConcurrency: 2,
 		},
 		Gemini: GeminiConfig{
-			Model: "gemini-2.0-flash-lite",
+			Model:          "gemini-2.0-flash-lite",
+			MaxConcurrency: 4,
+		},
+		Commit: CommitConfig{
+			Style:            "conventional",
+			MaxSubjectLength: 72,
+			BodyWrap:         72,
+		},
+		Agent: AgentConfig{
+			MaxSteps:       30,
+			CommandTimeout: 120,
 		},
 	}
 	
@@ -86,6 +159,24 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
+// MaxConcurrency returns the maximum number of parallel requests allowed for
+// provider. A value below 1 in the config file means no limit.
+func (c *Config) MaxConcurrency(provider string) int {
+	var limit int
+	switch provider {
+	case "openai":
+		limit = c.OpenAI.MaxConcurrency
+	case "cboe":
+		limit = c.CBOE.MaxConcurrency
+	case "gemini":
+		limit = c.Gemini.MaxConcurrency
+	}
+	if limit < 1 {
+		return 0
+	}
+	return limit
+}
+
 // Save saves the configuration to a file
 func (c *Config) Save() error {
 	configPath := GetConfigPath()
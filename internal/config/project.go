@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectDirName is the repo-local directory holding project-specific settings
+const ProjectDirName = ".llm-tool"
+
+// ReviewRule adds extra review instructions for files matching a glob pattern
+type ReviewRule struct {
+	Path         string `yaml:"path"`         // Glob pattern, e.g. "migrations/**"
+	Instructions string `yaml:"instructions"` // Extra instructions for matching files
+}
+
+// ReviewGuidelines holds a project's review guidelines and per-path rules
+type ReviewGuidelines struct {
+	Guidelines string       `yaml:"-"`     // Contents of .llm-tool/review.md
+	Rules      []ReviewRule `yaml:"rules"` // Rules from .llm-tool/review.yaml
+}
+
+// FindProjectDir walks up from startDir looking for a .llm-tool directory.
+// It returns an empty string if no project directory is found.
+func FindProjectDir(startDir string) (string, error) {
+	if startDir == "" {
+		startDir = "."
+	}
+
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", startDir, err)
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadReviewGuidelines loads review.md and review.yaml from the nearest
+// project directory above startDir. It returns nil if neither file exists.
+func LoadReviewGuidelines(startDir string) (*ReviewGuidelines, error) {
+	projectDir, err := FindProjectDir(startDir)
+	if err != nil || projectDir == "" {
+		return nil, err
+	}
+
+	guidelines := &ReviewGuidelines{}
+	found := false
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "review.md"))
+	if err == nil {
+		guidelines.Guidelines = string(data)
+		found = true
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read review guidelines: %w", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(projectDir, "review.yaml"))
+	if err == nil {
+		if err := yaml.Unmarshal(data, guidelines); err != nil {
+			return nil, fmt.Errorf("failed to parse review rules: %w", err)
+		}
+		found = true
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read review rules: %w", err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return guidelines, nil
+}
+
+// MatchingRules returns the rules whose pattern matches at least one of the given paths
+func (g *ReviewGuidelines) MatchingRules(paths []string) []ReviewRule {
+	var matched []ReviewRule
+	for _, rule := range g.Rules {
+		for _, p := range paths {
+			if fileutil.MatchGlob(rule.Path, p) {
+				matched = append(matched, rule)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// LoadProjectFile returns the contents of name inside the nearest project
+// directory above startDir, or an empty string if it does not exist
+func LoadProjectFile(startDir string, name string) (string, error) {
+	projectDir, err := FindProjectDir(startDir)
+	if err != nil || projectDir == "" {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(projectDir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(data), nil
+}
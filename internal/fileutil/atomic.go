@@ -0,0 +1,57 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces the file at path with content by writing a
+// temporary file in the same directory and renaming it into place. An
+// existing file keeps its permissions and, where supported, its owner; a
+// symlink is followed so that the file it points to is replaced. New files
+// are created with mode 0644.
+func WriteFileAtomic(path string, content []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".llm-tool-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp uses mode 0600, so set the final permissions explicitly
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if info != nil {
+		if err := copyOwner(tmpPath, info); err != nil {
+			return fmt.Errorf("failed to preserve owner: %w", err)
+		}
+	}
+
+	return os.Rename(tmpPath, path)
+}
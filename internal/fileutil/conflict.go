@@ -0,0 +1,71 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+)
+
+// Conflict is a staged file whose original changed on disk after the model
+// was given its content
+type Conflict struct {
+	File    StagedFile
+	Current string // Content now on disk
+	Deleted bool   // The file no longer exists
+}
+
+// Conflicts returns the staged files whose originals no longer match the
+// content the edit was based on, and new files that now exist
+func (sa *StagingArea) Conflicts() ([]Conflict, error) {
+	var conflicts []Conflict
+	for _, file := range sa.Files {
+		if file.OriginalHash == "" && !file.IsNew {
+			continue
+		}
+
+		current, err := os.ReadFile(file.OriginalPath)
+		switch {
+		case os.IsNotExist(err):
+			// A file staged for deletion that is already gone doesn't conflict
+			if !file.IsNew && !file.IsDelete {
+				conflicts = append(conflicts, Conflict{File: file, Deleted: true})
+			}
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", file.OriginalPath, err)
+		}
+
+		if file.IsNew || HashContent(current) != file.OriginalHash {
+			conflicts = append(conflicts, Conflict{File: file, Current: string(current)})
+		}
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict restages the file with the given original path with
+// content, which was reconciled with the file as it is now on disk, so that
+// applying it no longer conflicts
+func (sa *StagingArea) ResolveConflict(originalPath string, content string) error {
+	current, err := os.ReadFile(originalPath)
+	isNew := os.IsNotExist(err)
+	if err != nil && !isNew {
+		return fmt.Errorf("failed to read %s: %w", originalPath, err)
+	}
+
+	if _, err := sa.StageFile(originalPath, content, isNew); err != nil {
+		return err
+	}
+	for i := range sa.Files {
+		if sa.Files[i].OriginalPath != originalPath {
+			continue
+		}
+		if isNew {
+			// The file was deleted, so it is created afresh
+			sa.Files[i].OriginalContent = ""
+			sa.Files[i].OriginalHash = ""
+		} else {
+			sa.Files[i].OriginalContent = string(current)
+			sa.Files[i].OriginalHash = HashContent(current)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package fileutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDiffContext is the number of unchanged lines shown around each change
+const DefaultDiffContext = 3
+
+// DiffLine is a single line of a diff hunk. Kind is ' ' for context, '-' for a
+// removed line and '+' for an added line.
+type DiffLine struct {
+	Kind      byte
+	Text      string // The line without its newline
+	NoNewline bool   // The line is the last in its file and has no newline
+}
+
+// newDiffLine creates a diff line from a line that may end in a newline
+func newDiffLine(kind byte, line string) DiffLine {
+	text, hasNewline := strings.CutSuffix(line, "\n")
+	return DiffLine{Kind: kind, Text: text, NoNewline: !hasNewline}
+}
+
+// Hunk is a group of nearby changes with surrounding context, numbered as in
+// a unified diff
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []DiffLine
+}
+
+// Header returns the "@@ -a,b +c,d @@" line for the hunk. As in GNU diff, a
+// count of one is left out.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// String formats the hunk as in a unified diff
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header())
+	sb.WriteString("\n")
+	for _, line := range h.Lines {
+		sb.WriteByte(line.Kind)
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+		if line.NoNewline {
+			sb.WriteString(noNewlineMarker + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// noNewlineMarker follows a diff line that has no newline at the end of the file
+const noNewlineMarker = `\ No newline at end of file`
+
+// hunkRange formats one side of a hunk header
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// UnifiedDiff returns a unified diff of original and modified with the given
+// file names in its header, or "" if they are identical. The output can be
+// applied with patch or "git apply".
+func UnifiedDiff(oldName string, newName string, original string, modified string, context int) string {
+	hunks := DiffHunks(original, modified, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		sb.WriteString(hunk.String())
+	}
+	return sb.String()
+}
+
+// oldOffset returns the 0-based index of the first original line the hunk replaces
+func (h Hunk) oldOffset() int {
+	if h.OldLines == 0 {
+		// An insertion is numbered after the line it follows
+		return h.OldStart
+	}
+	return h.OldStart - 1
+}
+
+// DiffHunks computes a line diff of original and modified using the Myers
+// algorithm and groups the changes into hunks with context lines around them
+func DiffHunks(original string, modified string, context int) []Hunk {
+	ops := diffLines(splitLinesKeepEnds(original), splitLinesKeepEnds(modified))
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough that the
+		// context of the two would touch
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != ' ' {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+
+		start := max(0, i-context)
+		stop := min(len(ops), end+context+1)
+		hunks = append(hunks, newHunk(ops[start:stop]))
+		i = stop
+	}
+	return hunks
+}
+
+// ApplyHunks returns original with only the accepted hunks applied. hunks
+// must come from DiffHunks(original, ...).
+func ApplyHunks(original string, hunks []Hunk, accepted []bool) string {
+	lines := splitLinesKeepEnds(original)
+	var sb strings.Builder
+	pos := 0
+	for i, hunk := range hunks {
+		offset := hunk.oldOffset()
+		sb.WriteString(strings.Join(lines[pos:offset], ""))
+		for _, line := range hunk.Lines {
+			if line.Kind == ' ' || (accepted[i] && line.Kind == '+') || (!accepted[i] && line.Kind == '-') {
+				sb.WriteString(line.Text)
+				if !line.NoNewline {
+					sb.WriteString("\n")
+				}
+			}
+		}
+		pos = offset + hunk.OldLines
+	}
+	sb.WriteString(strings.Join(lines[pos:], ""))
+
+	return sb.String()
+}
+
+// splitLinesKeepEnds splits text into lines, each keeping its newline, so that
+// a missing newline at the end of the file shows up as a difference
+func splitLinesKeepEnds(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is a line of the edit script with its position in both files
+type diffOp struct {
+	DiffLine
+	oldIndex int
+	newIndex int
+}
+
+// newHunk builds a hunk from a run of edit script operations
+func newHunk(ops []diffOp) Hunk {
+	h := Hunk{}
+	for _, op := range ops {
+		h.Lines = append(h.Lines, op.DiffLine)
+		if op.Kind != '+' {
+			h.OldLines++
+		}
+		if op.Kind != '-' {
+			h.NewLines++
+		}
+	}
+
+	h.OldStart = ops[0].oldIndex
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	h.NewStart = ops[0].newIndex
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+	return h
+}
+
+// diffLines returns the shortest edit script turning a into b
+func diffLines(a []string, b []string) []diffOp {
+	// Unchanged lines at either end don't need the full algorithm
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{newDiffLine(' ', a[i]), i, i})
+	}
+	for _, op := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		op.oldIndex += prefix
+		op.newIndex += prefix
+		ops = append(ops, op)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, diffOp{newDiffLine(' ', a[len(a)-i]), len(a) - i, len(b) - i})
+	}
+	return ops
+}
+
+// myers implements the Myers O(ND) diff algorithm. Only the diagonals
+// reached at each step are kept for backtracking, so memory grows with the
+// square of the number of differences rather than the file size.
+func myers(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD == 0 {
+		return nil
+	}
+
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	finalD := 0
+search:
+	for d := 0; d <= maxD; d++ {
+		// Save the furthest points of the previous step for diagonals -d..d
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				finalD = d
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end, collecting operations in reverse
+	var reversed []diffOp
+	x, y := n, m
+	for d := finalD; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffOp{newDiffLine(' ', a[x]), x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, diffOp{newDiffLine('+', b[y]), x, y})
+		} else {
+			x--
+			reversed = append(reversed, diffOp{newDiffLine('-', a[x]), x, y})
+		}
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
@@ -0,0 +1,70 @@
+package fileutil
+
+import "testing"
+
+func TestDiffHunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		modified  string
+		wantHunks int
+		// skipPatch leaves out the round trip through ApplyUnifiedDiff, which
+		// works on lines and ignores "\ No newline at end of file"
+		skipPatch bool
+	}{
+		{name: "identical", original: "a\nb\n", modified: "a\nb\n", wantHunks: 0},
+		{name: "one change", original: "a\nb\nc\n", modified: "a\nB\nc\n", wantHunks: 1},
+		{name: "insertion at start", original: "b\n", modified: "a\nb\n", wantHunks: 1},
+		{name: "deletion at end", original: "a\nb\n", modified: "a\n", wantHunks: 1},
+		{name: "new file", original: "", modified: "a\nb\n", wantHunks: 1},
+		{name: "missing final newline", original: "a\nb\n", modified: "a\nb", wantHunks: 1, skipPatch: true},
+		{name: "distant changes", original: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", modified: "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n", wantHunks: 2},
+		{name: "nearby changes merge", original: "1\n2\n3\n4\n5\n", modified: "one\n2\n3\n4\nfive\n", wantHunks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := DiffHunks(tt.original, tt.modified, 3)
+			if len(hunks) != tt.wantHunks {
+				t.Fatalf("DiffHunks() returned %d hunks, want %d", len(hunks), tt.wantHunks)
+			}
+
+			all := make([]bool, len(hunks))
+			none := make([]bool, len(hunks))
+			for i := range all {
+				all[i] = true
+			}
+			if got := ApplyHunks(tt.original, hunks, all); got != tt.modified {
+				t.Errorf("ApplyHunks(all) = %q, want %q", got, tt.modified)
+			}
+			if got := ApplyHunks(tt.original, hunks, none); got != tt.original {
+				t.Errorf("ApplyHunks(none) = %q, want %q", got, tt.original)
+			}
+
+			if tt.wantHunks == 0 || tt.skipPatch {
+				return
+			}
+			diff := UnifiedDiff("a/file", "b/file", tt.original, tt.modified, 3)
+			got, failures, err := ApplyUnifiedDiff(tt.original, diff)
+			if err != nil || len(failures) > 0 {
+				t.Fatalf("ApplyUnifiedDiff() error = %v, failures = %v", err, failures)
+			}
+			if got != tt.modified {
+				t.Errorf("ApplyUnifiedDiff(UnifiedDiff()) = %q, want %q", got, tt.modified)
+			}
+		})
+	}
+}
+
+func TestApplyHunksPartially(t *testing.T) {
+	original := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	modified := "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+	hunks := DiffHunks(original, modified, 3)
+	if len(hunks) != 2 {
+		t.Fatalf("DiffHunks() returned %d hunks, want 2", len(hunks))
+	}
+	want := "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+	if got := ApplyHunks(original, hunks, []bool{false, true}); got != want {
+		t.Errorf("ApplyHunks() = %q, want %q", got, want)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -16,12 +16,28 @@ type StagedFile struct {
 	StagedPath   string
 	Content      string
 	IsNew        bool
+	IsDelete     bool     // The file is removed when the changes are applied
+	Problems     []string // Validation failures shown alongside the diff
+
+	// OriginalContent is the content the edit was based on, and OriginalHash
+	// its SHA-256. When set, applying refuses to overwrite a file whose
+	// content has changed since.
+	OriginalContent string
+	OriginalHash    string
 }
 
 // StagingArea manages files that have been processed and are ready for review
 type StagingArea struct {
 	Files      []StagedFile
 	StagingDir string
+	BaseDir    string // Staged paths mirror the original paths relative to this directory
+
+	// HistoryDir is where applies are recorded as transactions that can be
+	// undone; no transaction is recorded when it is empty
+	HistoryDir  string
+	Description string // Recorded with the transaction, e.g. the edit instructions
+
+	DiffOptions DiffOptions // How ShowDiff displays changes
 }
 
 // NewStagingArea creates a new staging area for processing files
@@ -32,24 +48,24 @@ func NewStagingArea() (*StagingArea, error) {
 		return nil, fmt.Errorf("failed to create staging directory: %w", err)
 	}
 
+	baseDir, err := os.Getwd()
+	if err != nil {
+		baseDir = "."
+	}
+
 	return &StagingArea{
-		Files:      make([]StagedFile, 0),
-		StagingDir: stagingDir,
+		Files:       make([]StagedFile, 0),
+		StagingDir:  stagingDir,
+		BaseDir:     baseDir,
+		DiffOptions: DefaultDiffOptions(),
 	}, nil
 }
 
 // StageFile adds a file to the staging area
 func (sa *StagingArea) StageFile(originalPath string, content string, isNew bool) (*StagedFile, error) {
-	// Create a matching path structure in the staging directory
-	var stagedPath string
-	if isNew {
-		// For new files, use just the basename to avoid path issues
-		stagedPath = filepath.Join(sa.StagingDir, filepath.Base(originalPath))
-	} else {
-		// For existing files, try to maintain relative structure
-		relPath := filepath.Base(originalPath)
-		stagedPath = filepath.Join(sa.StagingDir, relPath)
-	}
+	// Mirror the original directory structure so that files with the same
+	// name in different directories don't collide
+	stagedPath := filepath.Join(sa.StagingDir, MirrorPath(sa.BaseDir, originalPath))
 
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(stagedPath), 0755); err != nil {
@@ -68,10 +84,145 @@ func (sa *StagingArea) StageFile(originalPath string, content string, isNew bool
 		IsNew:        isNew,
 	}
 
+	// Restaging a file replaces the earlier version and its problems, but
+	// keeps the content it was based on
+	for i := range sa.Files {
+		if sa.Files[i].OriginalPath == originalPath {
+			stagedFile.OriginalContent = sa.Files[i].OriginalContent
+			stagedFile.OriginalHash = sa.Files[i].OriginalHash
+			sa.Files[i] = stagedFile
+			return &stagedFile, nil
+		}
+	}
+
 	sa.Files = append(sa.Files, stagedFile)
 	return &stagedFile, nil
 }
 
+// SetOriginal records the content that the staged file with the given
+// original path was based on, for conflict detection when applying
+func (sa *StagingArea) SetOriginal(originalPath string, content string) {
+	for i := range sa.Files {
+		if sa.Files[i].OriginalPath == originalPath {
+			sa.Files[i].OriginalContent = content
+			sa.Files[i].OriginalHash = HashContent([]byte(content))
+			return
+		}
+	}
+}
+
+// StageDeletion stages the removal of the file at originalPath. Its current
+// content is recorded, so applying refuses to delete a file that has changed
+// since.
+func (sa *StagingArea) StageDeletion(originalPath string) error {
+	content, err := os.ReadFile(originalPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", originalPath, err)
+	}
+
+	sa.Unstage(originalPath)
+	sa.Files = append(sa.Files, StagedFile{
+		OriginalPath:    originalPath,
+		IsDelete:        true,
+		OriginalContent: string(content),
+		OriginalHash:    HashContent(content),
+	})
+	return nil
+}
+
+// Unstage removes the file with the given original path from the staging area
+func (sa *StagingArea) Unstage(originalPath string) {
+	for i := range sa.Files {
+		if sa.Files[i].OriginalPath == originalPath {
+			if sa.Files[i].StagedPath != "" {
+				os.Remove(sa.Files[i].StagedPath)
+			}
+			sa.Files = append(sa.Files[:i], sa.Files[i+1:]...)
+			return
+		}
+	}
+}
+
+// MirrorPath returns path relative to baseDir, for reproducing a directory
+// tree under another root. Paths outside baseDir are placed under
+// "_external" followed by their absolute path.
+func MirrorPath(baseDir string, path string) string {
+	if rel, ok := RelativePath(baseDir, path); ok {
+		return rel
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	volume := filepath.VolumeName(absPath)
+	return filepath.Join("_external", strings.TrimSuffix(volume, ":"), strings.TrimPrefix(absPath, volume))
+}
+
+// RelativePath returns path relative to baseDir, and false if path is not
+// inside baseDir
+func RelativePath(baseDir string, path string) (string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	// Compare resolved paths, since the base is often a repository root
+	// reported by git with symlinks resolved
+	for _, candidate := range []string{absPath, resolveDir(absPath)} {
+		for _, base := range []string{baseDir, resolveDir(baseDir)} {
+			absBase, err := filepath.Abs(base)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(absBase, candidate); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return rel, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolveDir resolves symlinks in path, or only in its directory if path names
+// a file that doesn't exist yet
+func resolveDir(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir, file := filepath.Split(path)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		return filepath.Join(resolved, file)
+	}
+	return path
+}
+
+// AddProblems records validation failures for the staged file with the given original path
+func (sa *StagingArea) AddProblems(originalPath string, problems ...string) {
+	for i := range sa.Files {
+		if sa.Files[i].OriginalPath == originalPath {
+			sa.Files[i].Problems = append(sa.Files[i].Problems, problems...)
+			return
+		}
+	}
+}
+
+// ClearProblems removes the validation failures recorded for every staged file
+func (sa *StagingArea) ClearProblems() {
+	for i := range sa.Files {
+		sa.Files[i].Problems = nil
+	}
+}
+
+// HasProblems reports whether any staged file has validation failures
+func (sa *StagingArea) HasProblems() bool {
+	for _, file := range sa.Files {
+		if len(file.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Cleanup removes the staging directory
 func (sa *StagingArea) Cleanup() error {
 	return os.RemoveAll(sa.StagingDir)
@@ -80,90 +231,136 @@ func (sa *StagingArea) Cleanup() error {
 // ShowDiff displays the diff between original and staged files
 func (sa *StagingArea) ShowDiff() error {
 	for _, file := range sa.Files {
-		if file.IsNew {
-			fmt.Printf("New file: %s\n", file.OriginalPath)
-			highlightContent(file.Content)
-			continue
+		fmt.Println()
+		switch {
+		case file.IsNew:
+			WriteDiff(os.Stdout, "/dev/null", file.OriginalPath, "", file.Content, sa.DiffOptions)
+		case file.IsDelete:
+			WriteDiff(os.Stdout, file.OriginalPath, "/dev/null", file.OriginalContent, "", sa.DiffOptions)
+		default:
+			original, err := os.ReadFile(file.OriginalPath)
+			if err != nil {
+				return fmt.Errorf("failed to read original file: %w", err)
+			}
+			WriteDiff(os.Stdout, file.OriginalPath, file.OriginalPath, string(original), file.Content, sa.DiffOptions)
 		}
 
-		fmt.Printf("\nDiff for %s:\n", file.OriginalPath)
-		if err := displayDiff(file.OriginalPath, file.StagedPath); err != nil {
-			return fmt.Errorf("failed to display diff: %w", err)
-		}
+		showProblems(file.Problems)
 	}
 	return nil
 }
 
-// displayDiff shows the difference between two files using diff command
-func displayDiff(originalPath, stagedPath string) error {
-	// Check if diff command exists
-	diffCmd, err := exec.LookPath("diff")
-	if err == nil {
-		// Try to use diff -u with color if supported
-		cmd := exec.Command(diffCmd, "--color=always", "-u", originalPath, stagedPath)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
-		// Ignore exit code 1, which just means files differ
-		if err != nil && cmd.ProcessState.ExitCode() != 1 {
-			// Try without color if that failed
-			cmd = exec.Command(diffCmd, "-u", originalPath, stagedPath)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-			// Still ignore exit code 1
-			if err != nil && cmd.ProcessState.ExitCode() != 1 {
-				return fmt.Errorf("diff command failed: %w", err)
-			}
+// ApplyChanges writes all staged changes to their original locations. Nothing
+// is written if any file has changed since it was staged; see Conflicts.
+// Each file is written to a temporary file that is renamed into place, so a
+// failure never leaves a file half-written, and existing files keep their
+// permissions and ownership. If any file can't be written, the files already
+// written are restored, so the staged files are applied as a unit.
+func (sa *StagingArea) ApplyChanges() error {
+	conflicts, err := sa.Conflicts()
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		var paths []string
+		for _, conflict := range conflicts {
+			paths = append(paths, conflict.File.OriginalPath)
 		}
-		return nil
+		return fmt.Errorf("files changed on disk since they were edited:\n  %s", strings.Join(paths, "\n  "))
 	}
 
-	// Fall back to manual diff display if diff command isn't available
-	return manualDiff(originalPath, stagedPath)
-}
-
-// manualDiff is a fallback method to show diffs when the diff command is not available
-func manualDiff(originalPath, stagedPath string) error {
-	original, err := os.ReadFile(originalPath)
-	if err != nil {
-		return fmt.Errorf("failed to read original file: %w", err)
+	var tx *Transaction
+	if sa.HistoryDir != "" && len(sa.Files) > 0 {
+		tx, err = sa.beginTransaction(sa.HistoryDir, sa.Description)
+		if err != nil {
+			return fmt.Errorf("failed to record transaction: %w", err)
+		}
 	}
 
-	staged, err := os.ReadFile(stagedPath)
-	if err != nil {
-		return fmt.Errorf("failed to read staged file: %w", err)
+	var written []appliedFile
+	for _, file := range sa.Files {
+		applied, err := applyFile(file)
+		if err != nil {
+			// Apply all the files or none of them
+			rollback(written)
+			if tx != nil {
+				os.RemoveAll(filepath.Join(sa.HistoryDir, tx.ID))
+			}
+			return fmt.Errorf("%w; rolled back the %d files already written", err, len(written))
+		}
+		written = append(written, applied)
 	}
 
-	fmt.Printf("--- %s\n", originalPath)
-	fmt.Printf("+++ %s\n", stagedPath)
-	// Here we could implement a more sophisticated diff algorithm,
-	// but for simplicity, we'll just show the full content with + and - indicators
-	fmt.Println("Original:")
-	fmt.Print(string(original))
-	fmt.Println("\nModified:")
-	fmt.Print(string(staged))
+	for _, file := range written {
+		if file.deleted {
+			fmt.Printf("Deleted: %s\n", file.path)
+		} else {
+			fmt.Printf("Applied changes to: %s\n", file.path)
+		}
+	}
 
+	if tx != nil {
+		fmt.Printf("Recorded transaction %s (revert with: llm-tool undo %s)\n", tx.ID, tx.ID)
+	}
 	return nil
 }
 
-// ApplyChanges writes all staged changes to their original locations
-func (sa *StagingArea) ApplyChanges() error {
-	for _, file := range sa.Files {
-		// Create directory if it doesn't exist (especially for new files)
-		dir := filepath.Dir(file.OriginalPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+// appliedFile is a file written by ApplyChanges with what it replaced
+type appliedFile struct {
+	path     string
+	previous []byte
+	existed  bool
+	mode     os.FileMode
+	deleted  bool
+}
+
+// applyFile writes a staged file to its original location
+func applyFile(file StagedFile) (appliedFile, error) {
+	applied := appliedFile{path: file.OriginalPath, deleted: file.IsDelete}
+	previous, err := os.ReadFile(file.OriginalPath)
+	switch {
+	case err == nil:
+		applied.previous = previous
+		applied.existed = true
+		if info, err := os.Stat(file.OriginalPath); err == nil {
+			applied.mode = info.Mode().Perm()
 		}
+	case !os.IsNotExist(err):
+		return applied, fmt.Errorf("failed to read %s: %w", file.OriginalPath, err)
+	}
 
-		// Write file content to original location
-		if err := os.WriteFile(file.OriginalPath, []byte(file.Content), 0644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", file.OriginalPath, err)
+	if file.IsDelete {
+		if err := os.Remove(file.OriginalPath); err != nil && !os.IsNotExist(err) {
+			return applied, fmt.Errorf("failed to delete %s: %w", file.OriginalPath, err)
 		}
+		return applied, nil
+	}
 
-		fmt.Printf("Applied changes to: %s\n", file.OriginalPath)
+	// Create directory if it doesn't exist (especially for new files)
+	dir := filepath.Dir(file.OriginalPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return applied, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	// Write file content to original location
+	if err := WriteFileAtomic(file.OriginalPath, []byte(file.Content)); err != nil {
+		return applied, fmt.Errorf("failed to write file %s: %w", file.OriginalPath, err)
+	}
+	return applied, nil
+}
+
+// rollback restores files written by a failed apply, newest first
+func rollback(written []appliedFile) {
+	for i := len(written) - 1; i >= 0; i-- {
+		file := written[i]
+		if file.existed {
+			if err := WriteFileAtomic(file.path, file.previous); err == nil && file.deleted {
+				os.Chmod(file.path, file.mode)
+			}
+		} else {
+			os.Remove(file.path)
+		}
 	}
-	return nil
 }
 
 // ReadFileContent reads the content of a file, or from stdin if filename is "-"
@@ -185,8 +382,15 @@ func ReadFileContent(filename string) (string, error) {
 	return string(bytes), nil
 }
 
-// highlightContent prints the content with syntax highlighting
-func highlightContent(content string) {
-	green := color.New(color.FgGreen)
-	green.Println(content)
+// showProblems prints validation failures for a staged file
+func showProblems(problems []string) {
+	if len(problems) == 0 {
+		return
+	}
+
+	red := color.New(color.FgRed)
+	red.Println("Problems:")
+	for _, problem := range problems {
+		red.Printf("  ✗ %s\n", strings.ReplaceAll(strings.TrimRight(problem, "\n"), "\n", "\n    "))
+	}
 }
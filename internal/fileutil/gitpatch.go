@@ -0,0 +1,61 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Patch returns the staged changes as a patch that "git apply" accepts, with
+// paths relative to BaseDir. Each file is diffed against the content its edit
+// was based on, so the patch doesn't undo changes made on disk since.
+func (sa *StagingArea) Patch() (string, error) {
+	var sb strings.Builder
+	for _, file := range sa.Files {
+		rel, ok := RelativePath(sa.BaseDir, file.OriginalPath)
+		if !ok {
+			return "", fmt.Errorf("%s is outside %s", file.OriginalPath, sa.BaseDir)
+		}
+		name := filepath.ToSlash(rel)
+
+		original := file.OriginalContent
+		if !file.IsNew && file.OriginalHash == "" {
+			content, err := os.ReadFile(file.OriginalPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to read original file: %w", err)
+			}
+			original = string(content)
+		}
+
+		oldName, newName := "a/"+name, "b/"+name
+		switch {
+		case file.IsNew:
+			oldName = "/dev/null"
+		case file.IsDelete:
+			newName = "/dev/null"
+		}
+		diff := UnifiedDiff(oldName, newName, original, file.Content, DefaultDiffContext)
+		if diff == "" && !file.IsNew && !file.IsDelete {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", name, name)
+		switch {
+		case file.IsNew:
+			sb.WriteString("new file mode 100644\n")
+		case file.IsDelete:
+			fmt.Fprintf(&sb, "deleted file mode %s\n", gitFileMode(file.OriginalPath))
+		}
+		sb.WriteString(diff)
+	}
+	return sb.String(), nil
+}
+
+// gitFileMode returns the mode git records for the file at path
+func gitFileMode(path string) string {
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0111 != 0 {
+		return "100755"
+	}
+	return "100644"
+}
@@ -0,0 +1,55 @@
+package fileutil
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob reports whether a slash-separated path matches a glob pattern.
+// In addition to the path.Match syntax, a "**" segment matches zero or more
+// directories. Patterns without a slash are matched against the base name,
+// so "*.sql" matches SQL files at any depth.
+func MatchGlob(pattern, name string) bool {
+	pattern = filepath.ToSlash(strings.TrimPrefix(pattern, "./"))
+	name = filepath.ToSlash(strings.TrimPrefix(name, "./"))
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, expanding "**"
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive "**" segments
+			rest := pattern[1:]
+			for len(rest) > 0 && rest[0] == "**" {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
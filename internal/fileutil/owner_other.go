@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+// copyOwner is a no-op on platforms without Unix file ownership
+func copyOwner(path string, info os.FileInfo) error {
+	return nil
+}
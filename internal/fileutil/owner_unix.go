@@ -0,0 +1,28 @@
+//go:build unix
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives path the owner and group recorded in info. Changing the
+// owner needs privileges, so a permission error is ignored when the owner is
+// not the current user.
+func copyOwner(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	uid, gid := int(stat.Uid), int(stat.Gid)
+	if uid == os.Getuid() && gid == os.Getgid() {
+		return nil
+	}
+
+	if err := os.Lchown(path, uid, gid); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package fileutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Markers delimiting a search/replace block
+const (
+	searchMarker  = "<<<<<<< SEARCH"
+	dividerMarker = "======="
+	replaceMarker = ">>>>>>> REPLACE"
+)
+
+// maxContextFuzz is how many leading and trailing context lines of a diff
+// hunk may be dropped when it does not match exactly
+const maxContextFuzz = 2
+
+// SearchReplace is an edit that replaces one exact block of text with another
+type SearchReplace struct {
+	Search  string
+	Replace string
+}
+
+// PatchFailure describes an edit or hunk that could not be applied
+type PatchFailure struct {
+	Index  int    // 1-based position of the edit or hunk in the patch
+	Reason string // Why it could not be applied
+	Text   string // The text that could not be located
+}
+
+func (f PatchFailure) String() string {
+	return fmt.Sprintf("edit %d: %s", f.Index, f.Reason)
+}
+
+// ParseSearchReplace extracts search/replace blocks from text of the form:
+//
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+func ParseSearchReplace(text string) ([]SearchReplace, error) {
+	var edits []SearchReplace
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != searchMarker {
+			continue
+		}
+
+		start := i + 1
+		divider, end := -1, -1
+		for j := start; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == dividerMarker && divider < 0 {
+				divider = j
+			} else if trimmed == replaceMarker && divider >= 0 {
+				end = j
+				break
+			}
+		}
+		if divider < 0 || end < 0 {
+			return nil, fmt.Errorf("unterminated search/replace block starting at line %d", i+1)
+		}
+
+		edits = append(edits, SearchReplace{
+			Search:  joinPatchLines(lines[start:divider]),
+			Replace: joinPatchLines(lines[divider+1 : end]),
+		})
+		i = end
+	}
+
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("no search/replace blocks found")
+	}
+	return edits, nil
+}
+
+// ApplySearchReplace applies edits to content in order. Each search block must
+// match exactly once, either exactly or ignoring indentation and trailing
+// whitespace. Edits that cannot be located unambiguously are skipped and
+// reported rather than guessed at.
+func ApplySearchReplace(content string, edits []SearchReplace) (string, []PatchFailure) {
+	var failures []PatchFailure
+
+	for i, edit := range edits {
+		if edit.Search == "" {
+			// An empty search block appends to the end of the file
+			if strings.TrimSpace(content) == "" {
+				content = edit.Replace
+			} else {
+				content = strings.TrimRight(content, "\n") + "\n" + edit.Replace
+			}
+			continue
+		}
+
+		switch strings.Count(content, edit.Search) {
+		case 1:
+			content = strings.Replace(content, edit.Search, edit.Replace, 1)
+			continue
+		case 0:
+		default:
+			failures = append(failures, PatchFailure{Index: i + 1, Reason: "search text matches more than once", Text: edit.Search})
+			continue
+		}
+
+		// Fall back to a line-based match that ignores surrounding whitespace
+		lines := splitPatchLines(content)
+		search := splitPatchLines(edit.Search)
+		matches := findLines(lines, search, 0, len(lines), normalizeLine)
+		switch len(matches) {
+		case 0:
+			failures = append(failures, PatchFailure{Index: i + 1, Reason: "search text not found", Text: edit.Search})
+		case 1:
+			lines = spliceLines(lines, matches[0], len(search), splitPatchLines(edit.Replace))
+			content = joinPatchLines(lines)
+		default:
+			failures = append(failures, PatchFailure{Index: i + 1, Reason: "search text matches more than once", Text: edit.Search})
+		}
+	}
+
+	return content, failures
+}
+
+// diffHunk is a single hunk of a unified diff
+type diffHunk struct {
+	OldStart int
+	Old      []string // Context and removed lines
+	New      []string // Context and added lines
+	Leading  int      // Number of context lines before the first change
+	Trailing int      // Number of context lines after the last change
+}
+
+// ApplyUnifiedDiff applies a unified diff to content. Hunks are located by
+// their context anywhere in the file, ignoring whitespace differences and, if
+// needed, up to two lines of surrounding context. Hunks that don't match, or
+// match in more than one place, are skipped and reported rather than guessed
+// at, since the model's line numbers can't be trusted to pick between them.
+func ApplyUnifiedDiff(content string, diff string) (string, []PatchFailure, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", nil, err
+	}
+
+	lines := splitPatchLines(content)
+	var failures []PatchFailure
+	offset := 0
+
+	for i, hunk := range hunks {
+		expected := hunk.OldStart - 1
+		if len(hunk.Old) == 0 {
+			// An insertion is numbered after the line it follows
+			expected = hunk.OldStart
+		}
+		pos, old, newLines, err := locateHunk(lines, hunk, expected+offset)
+		if err != nil {
+			failures = append(failures, PatchFailure{Index: i + 1, Reason: err.Error(), Text: strings.Join(hunk.Old, "\n")})
+			continue
+		}
+
+		lines = spliceLines(lines, pos, old, newLines)
+		offset += len(newLines) - old
+	}
+
+	return joinPatchLines(lines), failures, nil
+}
+
+// parseUnifiedDiff extracts the hunks of a single-file unified diff
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var current *diffHunk
+	changed := false
+	changeEnd := 0 // Number of old lines up to and including the last change
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if changed {
+			current.Trailing = len(current.Old) - changeEnd
+		}
+		hunks = append(hunks, *current)
+		current = nil
+	}
+
+	markChange := func() {
+		if !changed {
+			current.Leading = len(current.Old)
+			changed = true
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			finish()
+			current = &diffHunk{OldStart: parseOldStart(line)}
+			changed = false
+		case current == nil:
+			// Skip headers such as "--- a/file" and "+++ b/file"
+			continue
+		case strings.HasPrefix(line, "-"):
+			markChange()
+			current.Old = append(current.Old, line[1:])
+			changeEnd = len(current.Old)
+		case strings.HasPrefix(line, "+"):
+			markChange()
+			current.New = append(current.New, line[1:])
+			changeEnd = len(current.Old)
+		case strings.HasPrefix(line, " "), line == "":
+			text := strings.TrimPrefix(line, " ")
+			current.Old = append(current.Old, text)
+			current.New = append(current.New, text)
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+			continue
+		default:
+			finish()
+		}
+	}
+	finish()
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no diff hunks found")
+	}
+
+	// Blank lines at the end of a model response are not real context
+	for i := range hunks {
+		h := &hunks[i]
+		for h.Trailing > 0 && h.Old[len(h.Old)-1] == "" && h.New[len(h.New)-1] == "" {
+			h.Old = h.Old[:len(h.Old)-1]
+			h.New = h.New[:len(h.New)-1]
+			h.Trailing--
+		}
+	}
+	return hunks, nil
+}
+
+// parseOldStart returns the old-file start line of a "@@ -a,b +c,d @@" header, or 0
+func parseOldStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "-") {
+		return 0
+	}
+	spec := strings.TrimPrefix(fields[1], "-")
+	if comma := strings.Index(spec, ","); comma >= 0 {
+		spec = spec[:comma]
+	}
+	n, _ := strconv.Atoi(spec)
+	return n
+}
+
+// locateHunk finds where hunk applies in lines, trying exact and then
+// whitespace-insensitive matches with progressively less context. It returns
+// the position, the number of lines to replace and their replacement. Context
+// that matches several places is an error: dropping context or whitespace
+// would only match more.
+func locateHunk(lines []string, hunk diffHunk, expected int) (int, int, []string, error) {
+	for fuzz := 0; fuzz <= maxContextFuzz; fuzz++ {
+		lead := min(fuzz, hunk.Leading)
+		trail := min(fuzz, hunk.Trailing)
+		if fuzz > 0 && lead == 0 && trail == 0 {
+			break
+		}
+
+		old := hunk.Old[lead : len(hunk.Old)-trail]
+		newLines := hunk.New[lead : len(hunk.New)-trail]
+
+		for _, normalize := range []func(string) string{identity, normalizeLine} {
+			if len(old) == 0 {
+				// Pure insertion without context: trust the line number
+				if expected+lead >= 0 && expected+lead <= len(lines) {
+					return expected + lead, 0, newLines, nil
+				}
+				continue
+			}
+
+			switch matches := findLines(lines, old, 0, len(lines), normalize); len(matches) {
+			case 0:
+				continue
+			case 1:
+				return matches[0], len(old), newLines, nil
+			default:
+				return 0, 0, nil, fmt.Errorf("hunk context matches %d places; add more context", len(matches))
+			}
+		}
+	}
+
+	return 0, 0, nil, fmt.Errorf("hunk context not found")
+}
+
+// findLines returns every index in lines[from:to] where needle starts,
+// comparing lines after applying normalize
+func findLines(lines []string, needle []string, from int, to int, normalize func(string) string) []int {
+	var matches []int
+	for i := from; i+len(needle) <= to; i++ {
+		match := true
+		for j, want := range needle {
+			if normalize(lines[i+j]) != normalize(want) {
+				match = false
+				break
+			}
+		}
+		if match {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// spliceLines replaces count lines at pos with replacement
+func spliceLines(lines []string, pos int, count int, replacement []string) []string {
+	result := make([]string, 0, len(lines)-count+len(replacement))
+	result = append(result, lines[:pos]...)
+	result = append(result, replacement...)
+	return append(result, lines[pos+count:]...)
+}
+
+// splitPatchLines splits text into lines without a trailing empty element
+func splitPatchLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// joinPatchLines joins lines into text with a trailing newline
+func joinPatchLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// normalizeLine ignores leading and trailing whitespace when matching lines
+func normalizeLine(line string) string {
+	return strings.TrimSpace(line)
+}
+
+func identity(line string) string {
+	return line
+}
@@ -0,0 +1,164 @@
+package fileutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		diff    string
+		want    string
+		reasons []string
+	}{
+		{
+			name:    "exact match",
+			content: "a\nb\nc\n",
+			diff:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "wrong line number",
+			content: "a\nb\nc\nd\n",
+			diff:    "@@ -40,3 +40,3 @@\n b\n-c\n+C\n d\n",
+			want:    "a\nb\nC\nd\n",
+		},
+		{
+			name:    "whitespace differences",
+			content: "func f() {\n\treturn 1\n}\n",
+			diff:    "@@ -1,3 +1,3 @@\n func f() {\n-    return 1\n+\treturn 2\n }\n",
+			want:    "func f() {\n\treturn 2\n}\n",
+		},
+		{
+			name:    "stale context dropped",
+			content: "x\nb\nc\ny\n",
+			diff:    "@@ -1,4 +1,4 @@\n stale\n b\n-c\n+C\n y\n",
+			want:    "x\nb\nC\ny\n",
+		},
+		{
+			name:    "several hunks",
+			content: "a\nb\nc\nd\ne\nf\n",
+			diff:    "@@ -1,2 +1,3 @@\n a\n+a2\n b\n@@ -5,2 +6,2 @@\n e\n-f\n+F\n",
+			want:    "a\na2\nb\nc\nd\ne\nF\n",
+		},
+		{
+			name:    "insertion into an empty file",
+			content: "",
+			diff:    "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "insertion without context",
+			content: "a\nc\n",
+			diff:    "@@ -1,0 +2 @@\n+b\n",
+			want:    "a\nb\nc\n",
+		},
+		{
+			name:    "ambiguous context",
+			content: "x = 1\nprint(x)\n\nx = 1\nprint(x)\n",
+			diff:    "@@ -4,2 +4,2 @@\n x = 1\n-print(x)\n+print(x + 1)\n",
+			want:    "x = 1\nprint(x)\n\nx = 1\nprint(x)\n",
+			reasons: []string{"hunk context matches 2 places; add more context"},
+		},
+		{
+			name:    "context not found",
+			content: "a\nb\n",
+			diff:    "@@ -1,2 +1,2 @@\n q\n-r\n+R\n",
+			want:    "a\nb\n",
+			reasons: []string{"hunk context not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, failures, err := ApplyUnifiedDiff(tt.content, tt.diff)
+			if err != nil {
+				t.Fatalf("ApplyUnifiedDiff() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ApplyUnifiedDiff() = %q, want %q", got, tt.want)
+			}
+			var reasons []string
+			for _, failure := range failures {
+				reasons = append(reasons, failure.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("ApplyUnifiedDiff() failures = %q, want %q", reasons, tt.reasons)
+			}
+		})
+	}
+
+	if _, _, err := ApplyUnifiedDiff("a\n", "no hunks here"); err == nil {
+		t.Error("ApplyUnifiedDiff() accepted a diff without hunks")
+	}
+}
+
+func TestSearchReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edits   string
+		want    string
+		reasons []string
+	}{
+		{
+			name:    "exact match",
+			content: "a\nb\nc\n",
+			edits:   "<<<<<<< SEARCH\nb\n=======\nB\n>>>>>>> REPLACE\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "indentation differences",
+			content: "if x {\n\ty()\n}\n",
+			edits:   "<<<<<<< SEARCH\n  y()\n=======\n\tz()\n>>>>>>> REPLACE\n",
+			want:    "if x {\n\tz()\n}\n",
+		},
+		{
+			name:    "empty search appends",
+			content: "a\n",
+			edits:   "<<<<<<< SEARCH\n=======\nb\n>>>>>>> REPLACE\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "ambiguous search",
+			content: "a\nb\na\n",
+			edits:   "<<<<<<< SEARCH\na\n=======\nA\n>>>>>>> REPLACE\n",
+			want:    "a\nb\na\n",
+			reasons: []string{"search text matches more than once"},
+		},
+		{
+			name:    "search not found",
+			content: "a\n",
+			edits:   "<<<<<<< SEARCH\nq\n=======\nQ\n>>>>>>> REPLACE\n",
+			want:    "a\n",
+			reasons: []string{"search text not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits, err := ParseSearchReplace(tt.edits)
+			if err != nil {
+				t.Fatalf("ParseSearchReplace() error = %v", err)
+			}
+			got, failures := ApplySearchReplace(tt.content, edits)
+			if got != tt.want {
+				t.Errorf("ApplySearchReplace() = %q, want %q", got, tt.want)
+			}
+			var reasons []string
+			for _, failure := range failures {
+				reasons = append(reasons, failure.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("ApplySearchReplace() failures = %q, want %q", reasons, tt.reasons)
+			}
+		})
+	}
+
+	if _, err := ParseSearchReplace("<<<<<<< SEARCH\na\n=======\n"); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Errorf("ParseSearchReplace() error = %v, want unterminated block", err)
+	}
+}
@@ -0,0 +1,297 @@
+package fileutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+// Diff display styles accepted by DiffOptions.Style
+const (
+	DiffStyleAuto       = "auto"
+	DiffStyleUnified    = "unified"
+	DiffStyleSideBySide = "side-by-side"
+)
+
+// sideBySideMinWidth is the terminal width from which the auto style shows
+// diffs side by side
+const sideBySideMinWidth = 160
+
+// DiffOptions controls how diffs are displayed. Colors follow the color
+// package, which disables them when NO_COLOR is set or stdout isn't a terminal.
+type DiffOptions struct {
+	Context int    // Unchanged lines shown around each change
+	Style   string // One of the DiffStyle constants
+	Width   int    // Terminal width; 0 detects it
+}
+
+// DefaultDiffOptions returns the options used unless configured otherwise
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{Context: DefaultDiffContext, Style: DiffStyleAuto}
+}
+
+// Colors used for diff output
+var (
+	diffHeaderColor  = color.New(color.Bold)
+	diffHunkColor    = color.New(color.FgCyan)
+	diffRemoveColor  = color.New(color.FgRed)
+	diffAddColor     = color.New(color.FgGreen)
+	diffRemoveWordHi = color.New(color.FgRed, color.ReverseVideo)
+	diffAddWordHi    = color.New(color.FgGreen, color.ReverseVideo)
+)
+
+// WriteDiff writes a diff of original and modified to w, as a unified diff or
+// side by side depending on opts
+func WriteDiff(w io.Writer, oldName string, newName string, original string, modified string, opts DiffOptions) {
+	diffHeaderColor.Fprintf(w, "--- %s", oldName)
+	fmt.Fprintln(w)
+	diffHeaderColor.Fprintf(w, "+++ %s", newName)
+	fmt.Fprintln(w)
+
+	hunks := DiffHunks(original, modified, opts.Context)
+	if len(hunks) == 0 {
+		fmt.Fprintln(w, "(no changes)")
+		return
+	}
+
+	width := opts.Width
+	if width <= 0 {
+		width = TerminalWidth()
+	}
+
+	if opts.Style == DiffStyleSideBySide || (opts.Style == DiffStyleAuto && width >= sideBySideMinWidth) {
+		for _, hunk := range hunks {
+			writeSideBySide(w, hunk, width)
+		}
+		return
+	}
+
+	for _, hunk := range hunks {
+		WriteHunk(w, hunk)
+	}
+}
+
+// WriteHunk writes a hunk in unified format. When a removed line is replaced
+// by a similar added line, the words that changed are highlighted.
+func WriteHunk(w io.Writer, hunk Hunk) {
+	diffHunkColor.Fprintln(w, hunk.Header())
+
+	lines := hunk.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			fmt.Fprintf(w, " %s\n", lines[i].Text)
+			writeNoNewline(w, lines[i])
+			i++
+			continue
+		}
+
+		// A block of removed lines followed by the added lines replacing them
+		removedEnd := i
+		for removedEnd < len(lines) && lines[removedEnd].Kind == '-' {
+			removedEnd++
+		}
+		addedEnd := removedEnd
+		for addedEnd < len(lines) && lines[addedEnd].Kind == '+' {
+			addedEnd++
+		}
+		removed, added := lines[i:removedEnd], lines[removedEnd:addedEnd]
+
+		removedText := make([]string, len(removed))
+		addedText := make([]string, len(added))
+		for j := range removed {
+			removedText[j] = diffRemoveColor.Sprint(removed[j].Text)
+		}
+		for j := range added {
+			addedText[j] = diffAddColor.Sprint(added[j].Text)
+		}
+		for j := 0; j < len(removed) && j < len(added); j++ {
+			if old, new, ok := highlightWords(removed[j].Text, added[j].Text); ok {
+				removedText[j], addedText[j] = old, new
+			}
+		}
+
+		for j, line := range removed {
+			fmt.Fprintf(w, "%s%s\n", diffRemoveColor.Sprint("-"), removedText[j])
+			writeNoNewline(w, line)
+		}
+		for j, line := range added {
+			fmt.Fprintf(w, "%s%s\n", diffAddColor.Sprint("+"), addedText[j])
+			writeNoNewline(w, line)
+		}
+		i = addedEnd
+	}
+}
+
+// writeNoNewline writes the marker for a line without a newline at the end of the file
+func writeNoNewline(w io.Writer, line DiffLine) {
+	if line.NoNewline {
+		fmt.Fprintln(w, noNewlineMarker)
+	}
+}
+
+// highlightWords diffs two lines word by word and returns them colored with
+// the changed words highlighted. It reports false if the lines have too
+// little in common for word highlighting to help.
+func highlightWords(old string, new string) (string, string, bool) {
+	oldWords, newWords := splitWords(old), splitWords(new)
+	ops := myers(oldWords, newWords)
+
+	common := 0
+	for _, op := range ops {
+		if op.Kind == ' ' {
+			common += len(op.Text)
+		}
+	}
+	if common*3 < max(len(old), len(new)) {
+		return "", "", false
+	}
+
+	var oldOut, newOut strings.Builder
+	for i := 0; i < len(ops); {
+		// Color runs of words with the same kind together
+		kind := ops[i].Kind
+		var run strings.Builder
+		for ; i < len(ops) && ops[i].Kind == kind; i++ {
+			run.WriteString(ops[i].Text)
+		}
+
+		switch kind {
+		case ' ':
+			oldOut.WriteString(diffRemoveColor.Sprint(run.String()))
+			newOut.WriteString(diffAddColor.Sprint(run.String()))
+		case '-':
+			oldOut.WriteString(diffRemoveWordHi.Sprint(run.String()))
+		case '+':
+			newOut.WriteString(diffAddWordHi.Sprint(run.String()))
+		}
+	}
+	return oldOut.String(), newOut.String(), true
+}
+
+// splitWords splits a line into runs of word characters, runs of spaces and
+// single punctuation characters
+func splitWords(line string) []string {
+	var words []string
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		words = append(words, string(runes[i:j]))
+		i = j
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// writeSideBySide writes a hunk as two columns, the original on the left and
+// the modified file on the right, with line numbers
+func writeSideBySide(w io.Writer, hunk Hunk, width int) {
+	diffHunkColor.Fprintln(w, hunk.Header())
+
+	// Each column has a line number gutter; " │ " separates the columns
+	column := (width - 3) / 2
+	textWidth := max(column-5, 10)
+
+	oldNo, newNo := hunk.OldStart, hunk.NewStart
+	if hunk.OldLines == 0 {
+		oldNo++
+	}
+	if hunk.NewLines == 0 {
+		newNo++
+	}
+
+	row := func(left *DiffLine, right *DiffLine) {
+		var sb strings.Builder
+		if left != nil {
+			text := fmt.Sprintf("%4d %s", oldNo, fitWidth(left.Text, textWidth))
+			if left.Kind == '-' {
+				text = diffRemoveColor.Sprint(text)
+			}
+			sb.WriteString(text)
+			oldNo++
+		} else {
+			sb.WriteString(strings.Repeat(" ", textWidth+5))
+		}
+
+		sb.WriteString(" │ ")
+
+		if right != nil {
+			text := fmt.Sprintf("%4d %s", newNo, fitWidth(right.Text, textWidth))
+			if right.Kind == '+' {
+				text = diffAddColor.Sprint(text)
+			}
+			sb.WriteString(text)
+			newNo++
+		}
+		fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+	}
+
+	lines := hunk.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			row(&lines[i], &lines[i])
+			i++
+			continue
+		}
+
+		removedEnd := i
+		for removedEnd < len(lines) && lines[removedEnd].Kind == '-' {
+			removedEnd++
+		}
+		addedEnd := removedEnd
+		for addedEnd < len(lines) && lines[addedEnd].Kind == '+' {
+			addedEnd++
+		}
+
+		removed, added := lines[i:removedEnd], lines[removedEnd:addedEnd]
+		for j := 0; j < len(removed) || j < len(added); j++ {
+			var left, right *DiffLine
+			if j < len(removed) {
+				left = &removed[j]
+			}
+			if j < len(added) {
+				right = &added[j]
+			}
+			row(left, right)
+		}
+		i = addedEnd
+	}
+}
+
+// fitWidth expands tabs and pads or truncates text to exactly width columns
+func fitWidth(text string, width int) string {
+	runes := []rune(strings.ReplaceAll(text, "\t", "    "))
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
+
+// TerminalWidth returns the width of the terminal on stdout, from $COLUMNS
+// or the terminal itself, or 80 if it can't be determined
+func TerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width := terminalWidth(os.Stdout); width > 0 {
+		return width
+	}
+	return 80
+}
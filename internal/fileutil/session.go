@@ -0,0 +1,213 @@
+package fileutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sessionFile is the name of the record kept in each session directory
+const sessionFile = "session.json"
+
+// Session is a staging area saved to disk so that it can be reviewed and
+// applied by a later command
+type Session struct {
+	ID          string        `json:"id"`
+	Time        time.Time     `json:"time"`
+	Description string        `json:"description"`
+	BaseDir     string        `json:"baseDir"`
+	Files       []SessionFile `json:"files"`
+}
+
+// SessionFile records a staged file. The staged content is kept under the
+// session's files directory, where it can be edited before applying.
+type SessionFile struct {
+	Path         string `json:"path"`             // Absolute path the file is applied to
+	Staged       string `json:"staged,omitempty"` // Staged copy, relative to the session directory
+	IsNew        bool   `json:"isNew"`
+	IsDelete     bool   `json:"isDelete,omitempty"`
+	Original     string `json:"original,omitempty"` // Content the edit was based on, relative to the session directory
+	OriginalHash string `json:"originalHash,omitempty"`
+}
+
+// SaveSession copies the staged files into a new session under sessionsDir
+func (sa *StagingArea) SaveSession(sessionsDir string) (*Session, error) {
+	now := time.Now()
+	session := &Session{
+		ID:          newRecordID(now),
+		Time:        now,
+		Description: sa.Description,
+		BaseDir:     sa.BaseDir,
+	}
+
+	dir := filepath.Join(sessionsDir, session.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	for _, file := range sa.Files {
+		path, err := filepath.Abs(file.OriginalPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", file.OriginalPath, err)
+		}
+		mirrored := MirrorPath(sa.BaseDir, path)
+		record := SessionFile{Path: path, IsNew: file.IsNew, IsDelete: file.IsDelete}
+		if !file.IsDelete {
+			record.Staged = filepath.Join("files", mirrored)
+			if err := writeSessionFile(dir, record.Staged, file.Content); err != nil {
+				return nil, err
+			}
+		}
+
+		if file.OriginalHash != "" {
+			record.Original = filepath.Join("originals", mirrored)
+			record.OriginalHash = file.OriginalHash
+			if err := writeSessionFile(dir, record.Original, file.OriginalContent); err != nil {
+				return nil, err
+			}
+		}
+		session.Files = append(session.Files, record)
+	}
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, sessionFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+	return session, nil
+}
+
+// writeSessionFile writes content to a path relative to a session directory
+func writeSessionFile(dir string, name string, content string) error {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create session subdirectory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListSessions returns the saved sessions, newest first
+func ListSessions(sessionsDir string) ([]*Session, error) {
+	entries, err := os.ReadDir(sessionsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged sessions: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		session, err := LoadSession(sessionsDir, entry.Name())
+		if err != nil {
+			// Skip sessions that were only partly saved
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Time.After(sessions[j].Time)
+	})
+	return sessions, nil
+}
+
+// LoadSession reads the session with the given ID
+func LoadSession(sessionsDir string, id string) (*Session, error) {
+	if !validRecordID(id) {
+		return nil, fmt.Errorf("invalid session ID %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(sessionsDir, id, sessionFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no staged session with ID %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// StagingArea returns a staging area holding the session's files as they are
+// now on disk, so that staged content edited by hand is what gets applied.
+// Staged copies that were deleted are left out. Restaging a file updates its
+// copy in the session.
+func (s *Session) StagingArea(sessionsDir string) (*StagingArea, error) {
+	dir := filepath.Join(sessionsDir, s.ID)
+	sa := &StagingArea{
+		Files:       make([]StagedFile, 0, len(s.Files)),
+		StagingDir:  filepath.Join(dir, "files"),
+		BaseDir:     s.BaseDir,
+		Description: s.Description,
+		DiffOptions: DefaultDiffOptions(),
+	}
+
+	wd, _ := os.Getwd()
+	for _, record := range s.Files {
+		var stagedPath string
+		var content []byte
+		if !record.IsDelete {
+			stagedPath = filepath.Join(dir, record.Staged)
+			var err error
+			content, err = os.ReadFile(stagedPath)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read staged copy of %s: %w", record.Path, err)
+			}
+		}
+
+		// Show paths relative to the working directory when they're inside it
+		path := record.Path
+		if rel, err := filepath.Rel(wd, path); wd != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = rel
+		}
+
+		file := StagedFile{
+			OriginalPath: path,
+			StagedPath:   stagedPath,
+			Content:      string(content),
+			IsNew:        record.IsNew,
+			IsDelete:     record.IsDelete,
+			OriginalHash: record.OriginalHash,
+		}
+		if record.Original != "" {
+			original, err := os.ReadFile(filepath.Join(dir, record.Original))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read original of %s: %w", record.Path, err)
+			}
+			file.OriginalContent = string(original)
+		}
+		sa.Files = append(sa.Files, file)
+	}
+	return sa, nil
+}
+
+// DiscardSession deletes the session with the given ID and its staged files
+func DiscardSession(sessionsDir string, id string) error {
+	if _, err := LoadSession(sessionsDir, id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(sessionsDir, id)); err != nil {
+		return fmt.Errorf("failed to discard session %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes when deciding
+// whether it is binary
+const binarySniffLen = 8000
+
+// TargetOptions controls how ExpandTargets turns arguments into files
+type TargetOptions struct {
+	Include []string // Glob patterns a file must match, if any are given
+	Exclude []string // Glob patterns that remove matching files
+
+	// Visible lists the files that aren't ignored, usually from
+	// git.VisibleFiles. Files found by walking a directory or glob are skipped
+	// unless they are listed. A nil slice disables ignore handling.
+	Visible []string
+
+	// Allowed restricts every target to the listed files, for selectors such
+	// as --git-tracked or --changed-since. A nil slice allows every file.
+	Allowed []string
+}
+
+// ExpandTargets expands file, directory and glob arguments into a sorted list
+// of text files. Directories are walked recursively and globs may use "**".
+// Files named explicitly skip the ignore check but are still subject to the
+// include, exclude and allowed filters. Binary files are skipped and returned
+// separately so that they can be reported.
+func ExpandTargets(args []string, opts TargetOptions) ([]string, []string, error) {
+	visible := canonicalSet(opts.Visible)
+	allowed := canonicalSet(opts.Allowed)
+
+	// Directories without any visible files, such as node_modules, are not walked
+	var visibleDirs map[string]bool
+	if visible != nil {
+		visibleDirs = make(map[string]bool)
+		for path := range visible {
+			for dir := filepath.Dir(path); !visibleDirs[dir]; dir = filepath.Dir(dir) {
+				visibleDirs[dir] = true
+			}
+		}
+	}
+	skipDir := func(dir string) bool {
+		return visibleDirs != nil && !visibleDirs[canonicalPath(dir)]
+	}
+
+	seen := make(map[string]bool)
+	var files, binary []string
+
+	add := func(path string, explicit bool) error {
+		path = filepath.Clean(path)
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+
+		key := canonicalPath(path)
+		if !explicit && visible != nil && !visible[key] {
+			return nil
+		}
+		if allowed != nil && !allowed[key] {
+			return nil
+		}
+		if !matchesFilters(path, opts.Include, opts.Exclude) {
+			return nil
+		}
+
+		isBinary, err := IsBinaryFile(path)
+		if err != nil {
+			return err
+		}
+		if isBinary {
+			binary = append(binary, path)
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	}
+
+	for _, arg := range args {
+		var matches []string
+		var err error
+
+		info, statErr := os.Stat(arg)
+		switch {
+		case statErr == nil && info.IsDir():
+			matches, err = walkFiles(arg, skipDir, func(string) bool { return true })
+		case statErr == nil:
+			if err := add(arg, true); err != nil {
+				return nil, nil, err
+			}
+			continue
+		case hasGlobMeta(arg):
+			// Unlike include and exclude patterns, a pattern without a slash
+			// only matches files in the current directory, as in the shell
+			segments := strings.Split(filepath.ToSlash(filepath.Clean(arg)), "/")
+			matches, err = walkFiles(globBase(segments), skipDir, func(path string) bool {
+				return matchSegments(segments, strings.Split(filepath.ToSlash(path), "/"))
+			})
+			if err == nil && len(matches) == 0 {
+				return nil, nil, fmt.Errorf("no files match %s", arg)
+			}
+		default:
+			return nil, nil, fmt.Errorf("failed to read %s: %w", arg, statErr)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sort.Strings(matches)
+		for _, path := range matches {
+			if err := add(path, false); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return files, binary, nil
+}
+
+// IsBinaryFile reports whether the start of the file contains a NUL byte
+func IsBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
+
+// walkFiles returns the regular files under root accepted by match, skipping
+// .git directories and the directories rejected by skipDir
+func walkFiles(root string, skipDir func(string) bool, match func(string) bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && (info.Name() == ".git" || skipDir(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && match(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// matchesFilters applies the include and exclude patterns to path
+func matchesFilters(path string, include []string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if MatchGlob(pattern, path) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if MatchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobMeta reports whether path contains glob syntax
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globBase returns the leading directories of a slash-separated pattern that
+// contain no glob syntax
+func globBase(segments []string) string {
+	var base []string
+	for _, segment := range segments[:len(segments)-1] {
+		if hasGlobMeta(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+
+	if len(base) == 0 {
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return "/"
+	}
+	return filepath.FromSlash(strings.Join(base, "/"))
+}
+
+// canonicalSet returns the canonical forms of paths, or nil if paths is nil
+func canonicalSet(paths []string) map[string]bool {
+	if paths == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		set[canonicalPath(path)] = true
+	}
+	return set
+}
+
+// canonicalPath returns an absolute path with symlinks resolved, so that paths
+// reported by git can be compared with paths found on disk
+func canonicalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+// terminalWidth is not supported on this platform; TerminalWidth falls back
+// to $COLUMNS or 80
+func terminalWidth(f *os.File) int {
+	return 0
+}
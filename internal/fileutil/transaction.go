@@ -0,0 +1,249 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// transactionFile is the name of the record kept in each transaction directory
+const transactionFile = "transaction.json"
+
+// Transaction records the files changed by one apply so that it can be undone
+type Transaction struct {
+	ID          string            `json:"id"`
+	Time        time.Time         `json:"time"`
+	Description string            `json:"description"`
+	WorkingDir  string            `json:"workingDir"`
+	Files       []TransactionFile `json:"files"`
+	UndoneAt    *time.Time        `json:"undoneAt,omitempty"`
+}
+
+// TransactionFile records the state of a single file before and after an apply
+type TransactionFile struct {
+	Path         string      `json:"path"`              // Absolute path of the file
+	Created      bool        `json:"created"`           // The file did not exist before the apply
+	Deleted      bool        `json:"deleted,omitempty"` // The apply removed the file
+	OriginalHash string      `json:"originalHash,omitempty"`
+	NewHash      string      `json:"newHash"`
+	Mode         os.FileMode `json:"mode,omitempty"` // Permissions of the original file
+	Backup       string      `json:"backup,omitempty"`
+}
+
+// HashContent returns the hex-encoded SHA-256 of content
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// newRecordID returns an ID for a transaction or session that sorts by time
+func newRecordID(now time.Time) string {
+	return fmt.Sprintf("%s-%04x", now.Format("20060102-150405"), rand.Intn(0x10000))
+}
+
+// validRecordID reports whether id can safely be used as a directory name
+func validRecordID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
+
+// beginTransaction backs up the current content of every staged file under
+// historyDir and saves a record of the apply before anything is written
+func (sa *StagingArea) beginTransaction(historyDir string, description string) (*Transaction, error) {
+	now := time.Now()
+	tx := &Transaction{
+		ID:          newRecordID(now),
+		Time:        now,
+		Description: description,
+	}
+	if wd, err := os.Getwd(); err == nil {
+		tx.WorkingDir = wd
+	}
+
+	dir := filepath.Join(historyDir, tx.ID)
+	if err := os.MkdirAll(filepath.Join(dir, "originals"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create transaction directory: %w", err)
+	}
+
+	for i, file := range sa.Files {
+		path, err := filepath.Abs(file.OriginalPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", file.OriginalPath, err)
+		}
+		record := TransactionFile{Path: path, Deleted: file.IsDelete}
+		if !file.IsDelete {
+			record.NewHash = HashContent([]byte(file.Content))
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			record.Created = true
+		case err != nil:
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		default:
+			original, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to back up %s: %w", path, err)
+			}
+			record.OriginalHash = HashContent(original)
+			record.Mode = info.Mode().Perm()
+			record.Backup = filepath.Join("originals", fmt.Sprintf("%d", i))
+			if err := os.WriteFile(filepath.Join(dir, record.Backup), original, 0600); err != nil {
+				return nil, fmt.Errorf("failed to back up %s: %w", path, err)
+			}
+		}
+		tx.Files = append(tx.Files, record)
+	}
+
+	if err := saveTransaction(historyDir, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ListTransactions returns the recorded transactions, newest first
+func ListTransactions(historyDir string) ([]*Transaction, error) {
+	entries, err := os.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var txs []*Transaction
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tx, err := LoadTransaction(historyDir, entry.Name())
+		if err != nil {
+			// Skip records from an apply that was interrupted
+			continue
+		}
+		txs = append(txs, tx)
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Time.After(txs[j].Time)
+	})
+	return txs, nil
+}
+
+// LoadTransaction reads the transaction with the given ID
+func LoadTransaction(historyDir string, id string) (*Transaction, error) {
+	if !validRecordID(id) {
+		return nil, fmt.Errorf("invalid transaction ID %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(historyDir, id, transactionFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no transaction with ID %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction %s: %w", id, err)
+	}
+
+	var tx Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction %s: %w", id, err)
+	}
+	return &tx, nil
+}
+
+// UndoTransaction restores the files changed or deleted by a transaction.
+// Files that were modified after the transaction, or recreated after it
+// deleted them, are conflicts: unless force is set, nothing is restored if any
+// file conflicts. It returns the paths restored.
+func UndoTransaction(historyDir string, tx *Transaction, force bool) ([]string, error) {
+	if tx.UndoneAt != nil {
+		return nil, fmt.Errorf("transaction %s was already undone at %s", tx.ID, tx.UndoneAt.Format(time.RFC3339))
+	}
+
+	var conflicts []string
+	var pending []TransactionFile
+	for _, file := range tx.Files {
+		current, err := os.ReadFile(file.Path)
+		switch {
+		case os.IsNotExist(err):
+			if file.Deleted {
+				pending = append(pending, file)
+			} else if !file.Created {
+				conflicts = append(conflicts, file.Path+" (deleted)")
+			}
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+
+		hash := HashContent(current)
+		switch {
+		case !file.Deleted && hash == file.NewHash:
+			pending = append(pending, file)
+		case !file.Created && hash == file.OriginalHash:
+			// Already back to its original content
+		default:
+			conflicts = append(conflicts, file.Path+" (modified)")
+			if force {
+				pending = append(pending, file)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 && !force {
+		return nil, fmt.Errorf("files changed since transaction %s; use --force to restore anyway:\n  %s", tx.ID, strings.Join(conflicts, "\n  "))
+	}
+
+	var restored []string
+	for _, file := range pending {
+		if file.Created {
+			if err := os.Remove(file.Path); err != nil {
+				return restored, fmt.Errorf("failed to remove %s: %w", file.Path, err)
+			}
+			restored = append(restored, file.Path)
+			continue
+		}
+
+		original, err := os.ReadFile(filepath.Join(historyDir, tx.ID, file.Backup))
+		if err != nil {
+			return restored, fmt.Errorf("failed to read backup of %s: %w", file.Path, err)
+		}
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := WriteFileAtomic(file.Path, original); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		if err := os.Chmod(file.Path, mode); err != nil {
+			return restored, fmt.Errorf("failed to restore mode of %s: %w", file.Path, err)
+		}
+		restored = append(restored, file.Path)
+	}
+
+	now := time.Now()
+	tx.UndoneAt = &now
+	if err := saveTransaction(historyDir, tx); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+// saveTransaction writes the record of tx
+func saveTransaction(historyDir string, tx *Transaction) error {
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(historyDir, tx.ID, transactionFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to save transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package git
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Context modes for BuildContext
+const (
+	ContextFull     = "full"     // Entire post-change file
+	ContextFunction = "function" // Enclosing declarations of changed lines (Go files only)
+)
+
+// defaultContextLines is the window used for non-Go files in function mode
+const defaultContextLines = 20
+
+// LineRange is an inclusive range of 1-based line numbers
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// FileChange describes the post-change lines touched in a single file of a diff
+type FileChange struct {
+	Path    string
+	Deleted bool
+	Ranges  []LineRange
+}
+
+// ParseDiff extracts the changed files and their post-change hunk ranges from a unified diff
+func ParseDiff(diff string) []FileChange {
+	var changes []FileChange
+	var current *FileChange
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			changes = append(changes, FileChange{})
+			current = &changes[len(changes)-1]
+			if idx := strings.LastIndex(line, " b/"); idx >= 0 {
+				current.Path = line[idx+len(" b/"):]
+			}
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Deleted = true
+		case strings.HasPrefix(line, "+++ b/"):
+			current.Path = strings.TrimPrefix(line, "+++ b/")
+		case strings.HasPrefix(line, "@@ "):
+			if r, ok := parseHunkHeader(line); ok {
+				current.Ranges = append(current.Ranges, r)
+			}
+		}
+	}
+
+	return changes
+}
+
+// parseHunkHeader returns the post-change range of a "@@ -a,b +c,d @@" header
+func parseHunkHeader(line string) (LineRange, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return LineRange{}, false
+	}
+
+	spec := strings.TrimPrefix(fields[2], "+")
+	count := 1
+	if comma := strings.Index(spec, ","); comma >= 0 {
+		n, err := strconv.Atoi(spec[comma+1:])
+		if err != nil {
+			return LineRange{}, false
+		}
+		count = n
+		spec = spec[:comma]
+	}
+
+	start, err := strconv.Atoi(spec)
+	if err != nil {
+		return LineRange{}, false
+	}
+
+	// Pure deletions have a zero count; anchor them on the surrounding line
+	if count == 0 {
+		if start == 0 {
+			start = 1
+		}
+		return LineRange{Start: start, End: start}, true
+	}
+	return LineRange{Start: start, End: start + count - 1}, true
+}
+
+// BuildContext collects post-change source surrounding the changes in diff.
+// mode is "full", "function", or a number of lines around each hunk. ref is
+// where the post-change files live (see ReadFileAt). Output stops once it
+// would exceed roughly tokenBudget tokens.
+func BuildContext(diff string, mode string, workingDir string, ref string, tokenBudget int) (string, error) {
+	lines := 0
+	if mode != ContextFull && mode != ContextFunction {
+		n, err := strconv.Atoi(mode)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("invalid context mode %q: use full, function or a number of lines", mode)
+		}
+		lines = n
+	}
+
+	var sb strings.Builder
+	budget := tokenBudget * 4 // Roughly four characters per token
+	var skipped []string
+
+	for _, change := range ParseDiff(diff) {
+		if change.Deleted || change.Path == "" {
+			continue
+		}
+
+		content, err := ReadFileAt(workingDir, ref, change.Path)
+		if err != nil {
+			// Binary files or files outside the tree are simply left out
+			skipped = append(skipped, change.Path)
+			continue
+		}
+
+		var ranges []LineRange
+		switch {
+		case mode == ContextFull:
+			ranges = []LineRange{{Start: 1, End: countLines(content)}}
+		case mode == ContextFunction && path.Ext(change.Path) == ".go":
+			ranges = enclosingDecls(content, change.Ranges)
+			if ranges == nil {
+				ranges = widenRanges(change.Ranges, defaultContextLines)
+			}
+		case mode == ContextFunction:
+			ranges = widenRanges(change.Ranges, defaultContextLines)
+		default:
+			ranges = widenRanges(change.Ranges, lines)
+		}
+
+		section := formatContext(change.Path, content, ranges)
+		if sb.Len()+len(section) > budget {
+			skipped = append(skipped, change.Path)
+			continue
+		}
+		sb.WriteString(section)
+	}
+
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "(Context omitted for: %s)\n", strings.Join(skipped, ", "))
+	}
+
+	return sb.String(), nil
+}
+
+// enclosingDecls returns the line ranges of the top-level Go declarations that
+// overlap the changed ranges, or nil if the file does not parse
+func enclosingDecls(content string, changed []LineRange) []LineRange {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	var ranges []LineRange
+	for _, decl := range file.Decls {
+		start := fset.Position(decl.Pos()).Line
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Doc != nil {
+			start = fset.Position(fn.Doc.Pos()).Line
+		}
+		r := LineRange{Start: start, End: fset.Position(decl.End()).Line}
+
+		for _, c := range changed {
+			if c.Start <= r.End && c.End >= r.Start {
+				ranges = append(ranges, r)
+				break
+			}
+		}
+	}
+
+	return mergeRanges(ranges)
+}
+
+// widenRanges extends each range by n lines on both sides and merges overlaps
+func widenRanges(ranges []LineRange, n int) []LineRange {
+	widened := make([]LineRange, 0, len(ranges))
+	for _, r := range ranges {
+		start := r.Start - n
+		if start < 1 {
+			start = 1
+		}
+		widened = append(widened, LineRange{Start: start, End: r.End + n})
+	}
+	return mergeRanges(widened)
+}
+
+// mergeRanges merges overlapping or adjacent ranges, which must be sorted by start
+func mergeRanges(ranges []LineRange) []LineRange {
+	var merged []LineRange
+	for _, r := range ranges {
+		if len(merged) > 0 && r.Start <= merged[len(merged)-1].End+1 {
+			if r.End > merged[len(merged)-1].End {
+				merged[len(merged)-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// formatContext renders the given line ranges of a file with line numbers
+func formatContext(path string, content string, ranges []LineRange) string {
+	fileLines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	var sb strings.Builder
+	for _, r := range ranges {
+		end := r.End
+		if end > len(fileLines) {
+			end = len(fileLines)
+		}
+		if r.Start > end {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "=== %s (lines %d-%d) ===\n", path, r.Start, end)
+		for i := r.Start; i <= end; i++ {
+			fmt.Fprintf(&sb, "%5d  %s\n", i, fileLines[i-1])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// countLines returns the number of lines in content
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
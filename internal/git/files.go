@@ -0,0 +1,61 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TrackedFiles returns the absolute paths of the files tracked in the
+// repository containing workingDir
+func TrackedFiles(workingDir string) ([]string, error) {
+	return listFiles(workingDir, "ls-files", "-z", "--full-name", "--", ":/")
+}
+
+// VisibleFiles returns the absolute paths of the tracked files and the
+// untracked files that aren't ignored by .gitignore or the exclude files
+func VisibleFiles(workingDir string) ([]string, error) {
+	return listFiles(workingDir, "ls-files", "-z", "--full-name", "--cached", "--others", "--exclude-standard", "--", ":/")
+}
+
+// ChangedSince returns the absolute paths of files that differ from ref in
+// the working tree, including new untracked files. Deleted files are omitted.
+func ChangedSince(ref string, workingDir string) ([]string, error) {
+	changed, err := listFiles(workingDir, "diff", "-z", "--name-only", "--diff-filter=d", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := listFiles(workingDir, "ls-files", "-z", "--full-name", "--others", "--exclude-standard", "--", ":/")
+	if err != nil {
+		return nil, err
+	}
+	return append(changed, untracked...), nil
+}
+
+// listFiles runs a git command printing NUL-separated repository-relative
+// paths and returns them as absolute paths
+func listFiles(workingDir string, args ...string) ([]string, error) {
+	root, err := RepoRoot(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runGit(workingDir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(out, "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
@@ -2,13 +2,26 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // GetDiff returns the git diff between the current branch and the specified branch
 func GetDiff(branchName string, workingDir string) (string, error) {
+	diff, _, err := GetDiffWithRef(branchName, workingDir)
+	return diff, err
+}
+
+// GetDiffWithRef returns the git diff between the current branch and the specified
+// branch, along with the ref holding the post-change side of the diff. An empty
+// ref means the post-change side is the working tree.
+func GetDiffWithRef(branchName string, workingDir string) (string, string, error) {
+	postRef := ""
 	cmd := exec.Command("git", "diff", branchName)
 	if workingDir != "" {
 		cmd.Dir = workingDir
@@ -21,16 +34,17 @@ func GetDiff(branchName string, workingDir string) (string, error) {
 
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("git diff error: %w: %s", err, stderr.String())
+		return "", "", fmt.Errorf("git diff error: %w: %s", err, stderr.String())
 	}
 
 	// If no diff, try to get diff between current branch and the given branch
 	if strings.TrimSpace(out.String()) == "" {
 		currentBranch, err := getCurrentBranch(workingDir)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 
+		postRef = currentBranch
 		cmd = exec.Command("git", "diff", fmt.Sprintf("%s...%s", branchName, currentBranch))
 		if workingDir != "" {
 			cmd.Dir = workingDir
@@ -43,11 +57,11 @@ func GetDiff(branchName string, workingDir string) (string, error) {
 
 		err = cmd.Run()
 		if err != nil {
-			return "", fmt.Errorf("git diff error: %w: %s", err, stderr.String())
+			return "", "", fmt.Errorf("git diff error: %w: %s", err, stderr.String())
 		}
 	}
 
-	return out.String(), nil
+	return out.String(), postRef, nil
 }
 
 // getCurrentBranch returns the name of the current branch
@@ -66,3 +80,228 @@ func getCurrentBranch(workingDir string) (string, error) {
 
 	return strings.TrimSpace(out.String()), nil
 }
+
+// ChangedFiles returns the paths touched by a unified git diff, in order of appearance
+func ChangedFiles(diff string) []string {
+	var files []string
+	for _, change := range ParseDiff(diff) {
+		if change.Path != "" {
+			files = append(files, change.Path)
+		}
+	}
+	return files
+}
+
+// RepoRoot returns the top-level directory of the repository containing workingDir
+func RepoRoot(workingDir string) (string, error) {
+	out, err := runGit(workingDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ReadFileAt returns the content of path (relative to the repository root) at ref.
+// An empty ref reads the working tree and ":" reads the index.
+func ReadFileAt(workingDir string, ref string, path string) (string, error) {
+	if ref == "" {
+		root, err := RepoRoot(workingDir)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return string(data), nil
+	}
+
+	if ref == ":" {
+		return runGit(workingDir, "show", ":"+path)
+	}
+	return runGit(workingDir, "show", ref+":"+path)
+}
+
+// runGit runs a git command in workingDir and returns its standard output
+func runGit(workingDir string, args ...string) (string, error) {
+	return runGitInput(workingDir, "", args...)
+}
+
+// runGitInput runs a git command in workingDir with input on its standard
+// input and returns its standard output
+func runGitInput(workingDir string, input string, args ...string) (string, error) {
+	return runGitEnv(workingDir, nil, input, args...)
+}
+
+// runGitEnv is runGitInput with env added to the environment
+func runGitEnv(workingDir string, env []string, input string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	if workingDir != "" {
+		cmd.Dir = workingDir
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s error: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out.String(), nil
+}
+
+// GetStagedDiff returns the diff of changes staged in the index
+func GetStagedDiff(workingDir string) (string, error) {
+	return runGit(workingDir, "diff", "--cached")
+}
+
+// GetStagedStat returns a short summary of the files changed in the index
+func GetStagedStat(workingDir string) (string, error) {
+	return runGit(workingDir, "diff", "--cached", "--stat")
+}
+
+// Commit creates a commit using the message in messageFile. Output from git
+// and any hooks is passed through to the terminal.
+func Commit(workingDir string, messageFile string) error {
+	cmd := exec.Command("git", "commit", "-F", messageFile)
+	if workingDir != "" {
+		cmd.Dir = workingDir
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	return nil
+}
+
+// LogEntry holds the metadata of a single commit as returned by Log
+type LogEntry struct {
+	Hash    string
+	Author  string
+	Date    string
+	Subject string
+	Body    string
+}
+
+// Log returns the commits in revRange (e.g. "main..HEAD"), newest first
+func Log(revRange string, workingDir string) ([]LogEntry, error) {
+	return readLog(workingDir, revRange)
+}
+
+// FileLog returns up to limit commits that changed path, newest first. An
+// empty path returns the most recent commits.
+func FileLog(workingDir string, path string, limit int) ([]LogEntry, error) {
+	args := []string{"-n", strconv.Itoa(limit), "HEAD"}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	return readLog(workingDir, args...)
+}
+
+// readLog runs git log with args and parses the commits it lists
+func readLog(workingDir string, args ...string) ([]LogEntry, error) {
+	// Fields are separated by \x1f and records by \x1e, which never appear in messages
+	out, err := runGit(workingDir, append([]string{"log", "--no-merges", "--format=%h%x1f%an%x1f%as%x1f%s%x1f%b%x1e"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	for _, record := range strings.Split(out, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, "\x1f", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		entries = append(entries, LogEntry{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    fields[2],
+			Subject: fields[3],
+			Body:    strings.TrimSpace(fields[4]),
+		})
+	}
+
+	return entries, nil
+}
+
+// FormatLog renders log entries as plain text for use in prompts
+func FormatLog(entries []LogEntry) string {
+	var sb strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&sb, "commit %s (%s, %s)\n%s\n", entry.Hash, entry.Author, entry.Date, entry.Subject)
+		if entry.Body != "" {
+			sb.WriteString("\n" + entry.Body + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Blame returns git blame output for lines start to end of path, or the
+// whole file if end is 0
+func Blame(workingDir string, path string, start int, end int) (string, error) {
+	args := []string{"blame", "--date=short"}
+	if end > 0 {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", max(start, 1), end))
+	}
+	return runGit(workingDir, append(args, "--", path)...)
+}
+
+// GetRangeDiff returns the diff for revRange, e.g. "v1.0..v1.1" or "main...HEAD"
+func GetRangeDiff(revRange string, workingDir string) (string, error) {
+	return runGit(workingDir, "diff", revRange)
+}
+
+// MergeFile performs a three-way merge of the changes from base to current
+// and from base to other with "git merge-file". It returns the merged text
+// and whether it merged cleanly; conflicting regions are marked in the text
+// with the usual conflict markers.
+func MergeFile(current string, base string, other string, otherLabel string) (string, bool, error) {
+	dir, err := os.MkdirTemp("", "llm-tool-merge-*")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create merge directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := make([]string, 3)
+	for i, content := range []string{current, base, other} {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%d", i))
+		if err := os.WriteFile(paths[i], []byte(content), 0600); err != nil {
+			return "", false, fmt.Errorf("failed to write merge input: %w", err)
+		}
+	}
+
+	cmd := exec.Command("git", "merge-file", "-p", "-L", "current", "-L", "original", "-L", otherLabel, paths[0], paths[1], paths[2])
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err == nil {
+		return out.String(), true, nil
+	}
+
+	// A positive exit status is the number of conflicts
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return out.String(), false, nil
+	}
+	return "", false, fmt.Errorf("git merge-file error: %w: %s", err, strings.TrimSpace(stderr.String()))
+}
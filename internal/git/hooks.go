@@ -0,0 +1,142 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HookMarker identifies hook scripts written by llm-tool
+const HookMarker = "# Installed by llm-tool"
+
+// HookBypassEnv skips llm-tool hooks when set to a non-empty value.
+// Chained hooks still run.
+const HookBypassEnv = "LLM_TOOL_SKIP_HOOKS"
+
+// chainedSuffix is appended to the name of a pre-existing hook that llm-tool runs first
+const chainedSuffix = ".llm-tool-chained"
+
+// stdinHooks lists the hooks that receive input on stdin
+var stdinHooks = map[string]bool{
+	"pre-push":              true,
+	"pre-receive":           true,
+	"post-receive":          true,
+	"post-rewrite":          true,
+	"reference-transaction": true,
+}
+
+// HooksDir returns the directory git runs hooks from, honouring core.hooksPath
+func HooksDir(workingDir string) (string, error) {
+	out, err := runGit(workingDir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		base := workingDir
+		if base == "" {
+			base = "."
+		}
+		dir = filepath.Join(base, dir)
+	}
+	return filepath.Abs(dir)
+}
+
+// InstallHook writes the named hook to run body, a POSIX shell snippet. A
+// pre-existing hook that was not written by llm-tool is kept and chained: it
+// runs first with the same arguments, and a failure aborts the hook. Setting
+// HookBypassEnv skips body. For hooks that read stdin, such as pre-push, the
+// input is captured in $hook_input so that both hooks can read it.
+func InstallHook(workingDir string, name string, body string) (string, error) {
+	dir, err := HooksDir(workingDir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	hookPath := filepath.Join(dir, name)
+	chainedPath := hookPath + chainedSuffix
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), HookMarker) {
+		if _, err := os.Stat(chainedPath); err == nil {
+			return "", fmt.Errorf("cannot chain existing %s hook: %s already exists", name, chainedPath)
+		}
+		if err := os.Rename(hookPath, chainedPath); err != nil {
+			return "", fmt.Errorf("failed to preserve existing %s hook: %w", name, err)
+		}
+		fmt.Printf("Existing %s hook will run first (moved to %s)\n", name, chainedPath)
+	}
+
+	if err := os.WriteFile(hookPath, []byte(hookScript(name, body)), 0755); err != nil {
+		return "", fmt.Errorf("failed to write %s hook: %w", name, err)
+	}
+	// WriteFile keeps the mode of an existing file, so make sure it is executable
+	if err := os.Chmod(hookPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to make %s hook executable: %w", name, err)
+	}
+	return hookPath, nil
+}
+
+// UninstallHook removes an llm-tool hook and restores any hook it chained.
+// It reports whether an llm-tool hook was found.
+func UninstallHook(workingDir string, name string) (bool, error) {
+	dir, err := HooksDir(workingDir)
+	if err != nil {
+		return false, err
+	}
+
+	hookPath := filepath.Join(dir, name)
+	existing, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s hook: %w", name, err)
+	}
+	if !strings.Contains(string(existing), HookMarker) {
+		return false, nil
+	}
+
+	if err := os.Remove(hookPath); err != nil {
+		return false, fmt.Errorf("failed to remove %s hook: %w", name, err)
+	}
+
+	chainedPath := hookPath + chainedSuffix
+	if _, err := os.Stat(chainedPath); err == nil {
+		if err := os.Rename(chainedPath, hookPath); err != nil {
+			return true, fmt.Errorf("failed to restore original %s hook: %w", name, err)
+		}
+		fmt.Printf("Restored original %s hook\n", name)
+	}
+	return true, nil
+}
+
+// hookScript wraps body with stdin capture, chaining and the bypass check
+func hookScript(name string, body string) string {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString(HookMarker + "\n")
+	fmt.Fprintf(&sb, "# Set %s=1 to skip this hook.\n\n", HookBypassEnv)
+
+	if stdinHooks[name] {
+		sb.WriteString("hook_input=$(cat)\n\n")
+	}
+
+	fmt.Fprintf(&sb, "chained=\"$(dirname \"$0\")/%s%s\"\n", name, chainedSuffix)
+	sb.WriteString("if [ -x \"$chained\" ]; then\n")
+	if stdinHooks[name] {
+		sb.WriteString("\tprintf '%s\\n' \"$hook_input\" | \"$chained\" \"$@\" || exit $?\n")
+	} else {
+		sb.WriteString("\t\"$chained\" \"$@\" || exit $?\n")
+	}
+	sb.WriteString("fi\n\n")
+
+	fmt.Fprintf(&sb, "if [ -n \"$%s\" ]; then\n\texit 0\nfi\n\n", HookBypassEnv)
+	sb.WriteString(strings.TrimSpace(body) + "\n")
+	return sb.String()
+}
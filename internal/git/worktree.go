@@ -0,0 +1,56 @@
+package git
+
+import "strings"
+
+// AddWorktree checks out base in a new worktree at path. With a branch name,
+// a new branch is created at base and checked out; otherwise HEAD is detached.
+func AddWorktree(workingDir string, path string, branch string, base string) error {
+	args := []string{"worktree", "add", "--quiet"}
+	if branch != "" {
+		args = append(args, "-b", branch)
+	} else {
+		args = append(args, "--detach")
+	}
+	_, err := runGit(workingDir, append(args, path, base)...)
+	return err
+}
+
+// RemoveWorktree deletes the worktree at path, discarding any changes in it
+func RemoveWorktree(workingDir string, path string) error {
+	_, err := runGit(workingDir, "worktree", "remove", "--force", path)
+	return err
+}
+
+// ApplyPatch applies a patch to the working tree and index of workingDir
+func ApplyPatch(workingDir string, patch string) error {
+	_, err := runGitInput(workingDir, patch, "apply", "--index", "-")
+	return err
+}
+
+// CommitIndex commits the changes in the index with message and returns the
+// hash of the new commit. Hooks are skipped: --no-verify skips pre-commit and
+// commit-msg, and HookBypassEnv skips llm-tool's prepare-commit-msg hook.
+func CommitIndex(workingDir string, message string) (string, error) {
+	env := []string{HookBypassEnv + "=1"}
+	if _, err := runGitEnv(workingDir, env, message, "commit", "--quiet", "--no-verify", "-F", "-"); err != nil {
+		return "", err
+	}
+	out, err := runGit(workingDir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// StashPush stashes the changes in the working tree and index of workingDir.
+// The stash list is shared by all worktrees of a repository.
+func StashPush(workingDir string, message string) error {
+	_, err := runGit(workingDir, "stash", "push", "--quiet", "-m", message)
+	return err
+}
+
+// DeleteBranch deletes a local branch, even if it isn't merged
+func DeleteBranch(workingDir string, branch string) error {
+	_, err := runGit(workingDir, "branch", "--quiet", "-D", branch)
+	return err
+}
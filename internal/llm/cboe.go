@@ -166,18 +166,8 @@ func (c *CBOEClient) StreamResponse(ctx context.Context, prompt string, model st
 }
 
 // ReviewCodeDiff reviews a git diff using the CBOE API
-func (c *CBOEClient) ReviewCodeDiff(ctx context.Context, diff string, model string) error {
-	prompt := fmt.Sprintf(`Review this git diff and provide actionable feedback:
-
-%s
-
-Please analyze:
-1. Code quality issues
-2. Potential bugs
-3. Security concerns
-4. Performance considerations
-5. Suggested improvements
-`, diff)
+func (c *CBOEClient) ReviewCodeDiff(ctx context.Context, review ReviewRequest, model string) (string, error) {
+	prompt := buildReviewPrompt(review)
 
 	// Create a system message and user message
 	reqBody := cboeCompletionRequest{
@@ -185,7 +175,7 @@ Please analyze:
 			{
 				Role: "system",
 				Content: []cboeContent{
-					{Text: "You are a helpful code reviewer. Provide clear, concise, and constructive feedback on git diffs."},
+					{Text: buildReviewSystemPrompt(review.Guidelines)},
 				},
 			},
 			{
@@ -211,7 +201,7 @@ Please analyze:
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// For code review, using non-streaming endpoint might be more appropriate
@@ -223,7 +213,7 @@ Please analyze:
 		strings.NewReader(string(jsonData)),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -231,23 +221,23 @@ Please analyze:
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return "", fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, body)
+		return "", fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, body)
 	}
 
 	// Parse the response
 	var response cboeCompletionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if response.Error != "" {
-		return fmt.Errorf("API returned error: %s", response.Error)
+		return "", fmt.Errorf("API returned error: %s", response.Error)
 	}
 
 	fmt.Print("\n=== Code Review ===\n")
@@ -265,43 +255,73 @@ Please analyze:
 	}
 	
 	fmt.Println("\n=== End of Review ===")
-	return nil
+	return response.Answer, nil
 }
 
 // RefactorFile refactors a file based on user instructions using the CBOE API
 func (c *CBOEClient) RefactorFile(ctx context.Context, filename string, content string, instructions string, model string) (string, error) {
-	prompt := fmt.Sprintf(`Refactor the following file based on these instructions:
+	return refactorFile(ctx, c, filename, content, instructions, model)
+}
 
-Instructions:
-%s
+// Complete returns a single non-streaming response to prompt using the CBOE API
+func (c *CBOEClient) Complete(ctx context.Context, systemPrompt string, prompt string, model string) (string, error) {
+	return c.send(ctx, []cboeMessage{
+		{
+			Role: "system",
+			Content: []cboeContent{
+				{Text: systemPrompt},
+			},
+		},
+		{
+			Role: "user",
+			Content: []cboeContent{
+				{Text: prompt},
+			},
+		},
+	})
+}
+
+// CompleteJSON asks for JSON matching schema using the CBOE API. The API has
+// no structured output mode, so the schema is given in the system prompt.
+func (c *CBOEClient) CompleteJSON(ctx context.Context, systemPrompt string, prompt string, schema json.RawMessage, model string) (string, error) {
+	return c.Complete(ctx, jsonSystemPrompt(systemPrompt, schema), prompt, model)
+}
 
-Filename: %s
+// Chat holds a conversation using the CBOE API. The API can't call tools, so
+// it returns ErrToolsNotSupported if any are given.
+func (c *CBOEClient) Chat(ctx context.Context, chat ChatRequest, onEvent func(StreamEvent)) (Message, error) {
+	if len(chat.Tools) > 0 {
+		return Message{}, ErrToolsNotSupported
+	}
+	if err := validateChatRequest(chat); err != nil {
+		return Message{}, err
+	}
 
-Content:
-%s
+	var messages []cboeMessage
+	for _, message := range chat.Messages {
+		if message.Role == RoleTool || len(message.ToolCalls) > 0 {
+			return Message{}, ErrToolsNotSupported
+		}
+		messages = append(messages, cboeMessage{
+			Role:    message.Role,
+			Content: []cboeContent{{Text: message.Content}},
+		})
+	}
 
-Please provide the complete refactored file content, maintaining the original functionality unless the instructions 
-specifically require changes. Keep all imports and package declarations.`, 
-		instructions, filename, content)
+	answer, err := c.send(ctx, messages)
+	if err != nil {
+		return Message{}, err
+	}
+	emit(onEvent, StreamEvent{Text: answer})
+	return Message{Role: RoleAssistant, Content: answer}, nil
+}
 
-	// Create system message and user message
+// send posts messages to the CBOE chat API and returns the answer
+func (c *CBOEClient) send(ctx context.Context, messages []cboeMessage) (string, error) {
 	reqBody := cboeCompletionRequest{
-		Messages: []cboeMessage{
-			{
-				Role: "system",
-				Content: []cboeContent{
-					{Text: "You are an expert software engineer tasked with refactoring code files. Provide only the refactored code without explanations unless explicitly asked."},
-				},
-			},
-			{
-				Role: "user",
-				Content: []cboeContent{
-					{Text: prompt},
-				},
-			},
-		},
-		Email: c.email,
-		Token: c.token,
+		Messages: messages,
+		Email:    c.email,
+		Token:    c.token,
 	}
 
 	// Add datasource if configured
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const changesSystemPrompt = "You are a senior engineer who writes clear, accurate release documentation from git history. Describe only changes that are present in the commits and diff you are given."
+
+// GeneratePRDescription asks the model for a pull request title and Markdown
+// body covering the given commits and diff. If template is set, the body
+// follows its structure.
+func GeneratePRDescription(ctx context.Context, client Client, log string, diff string, template string, model string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(`Write a pull request description for the following changes.
+
+The first line of your reply must be the pull request title: a single line under 72 characters, without Markdown formatting.
+Then leave a blank line and write the body in Markdown: summarize what changed and why, call out breaking changes, and note anything reviewers should test.
+Reply with the title and body only.
+`)
+
+	if strings.TrimSpace(template) != "" {
+		fmt.Fprintf(&sb, `
+Fill in this template for the body, keeping its headings and checklists:
+
+%s
+`, template)
+	}
+
+	fmt.Fprintf(&sb, "\nCommits:\n%s\nDiff:\n%s", log, truncateDiff(diff))
+
+	description, err := client.Complete(ctx, changesSystemPrompt, sb.String(), model)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stripMarkdownFence(description)) + "\n", nil
+}
+
+// GenerateChangelog asks the model for a Markdown changelog of the given
+// commits, grouped into features, fixes and breaking changes. If template is
+// set, the changelog follows its structure.
+func GenerateChangelog(ctx context.Context, client Client, title string, log string, diff string, template string, model string) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Write a changelog entry in Markdown for %s.
+
+Start with a "## %s" heading, then group the changes under "### Breaking Changes", "### Features", "### Fixes" and "### Other Changes", omitting empty groups.
+Write one concise bullet per user-visible change, merging commits that belong together, and end each bullet with the related short commit hashes in parentheses.
+Leave out purely internal changes such as formatting or CI tweaks unless nothing else changed.
+Reply with the changelog only.
+`, title, title)
+
+	if strings.TrimSpace(template) != "" {
+		fmt.Fprintf(&sb, `
+Follow this template instead of the default layout:
+
+%s
+`, template)
+	}
+
+	fmt.Fprintf(&sb, "\nCommits:\n%s\nDiff:\n%s", log, truncateDiff(diff))
+
+	changelog, err := client.Complete(ctx, changesSystemPrompt, sb.String(), model)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stripMarkdownFence(changelog)) + "\n", nil
+}
+
+// stripMarkdownFence removes a code fence wrapped around an entire response
+func stripMarkdownFence(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+		return text
+	}
+
+	trimmed = strings.TrimSuffix(trimmed, "```")
+	if newline := strings.Index(trimmed, "\n"); newline >= 0 {
+		return trimmed[newline+1:]
+	}
+	return text
+}
@@ -2,19 +2,48 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/EricBriscoe/llm-tool/internal/config"
 )
 
+// ErrOutputTruncated is returned, along with the partial output, when the
+// model stopped because it reached its output token limit
+var ErrOutputTruncated = errors.New("model output was truncated at the token limit")
+
 // Client defines the interface for LLM API clients
 type Client interface {
 	StreamResponse(ctx context.Context, prompt string, model string) error
-	ReviewCodeDiff(ctx context.Context, diff string, model string) error
+	ReviewCodeDiff(ctx context.Context, req ReviewRequest, model string) (string, error)
 	RefactorFile(ctx context.Context, filename string, content string, instructions string, model string) (string, error)
+	// Complete returns a single response to prompt. If the output hit the
+	// token limit it returns the partial text with ErrOutputTruncated.
+	Complete(ctx context.Context, systemPrompt string, prompt string, model string) (string, error)
+	// CompleteJSON is like Complete but asks for JSON matching schema, using
+	// the provider's structured output mode where it has one. The response
+	// is not validated; see CompleteStructured.
+	CompleteJSON(ctx context.Context, systemPrompt string, prompt string, schema json.RawMessage, model string) (string, error)
+	// Chat sends a conversation, offering the model req.Tools, and returns
+	// the assistant's reply, calling onEvent, if not nil, as text and tool
+	// calls arrive. The caller runs the tool calls in the reply and sends
+	// their results back with a further Chat. If the output hit the token
+	// limit it returns the partial reply with ErrOutputTruncated.
+	Chat(ctx context.Context, req ChatRequest, onEvent func(StreamEvent)) (Message, error)
 	ClearChatHistory() error
 }
 
+// ReviewRequest describes a code review to perform
+type ReviewRequest struct {
+	Diff       string // Unified diff to review
+	Guidelines string // Project-specific guidelines added to the system prompt
+	Context    string // Post-change source surrounding the changes, if requested
+	// RequireSeverity asks the reviewer to finish with an overall severity line
+	// that ParseReviewSeverity can read
+	RequireSeverity bool
+}
+
 // NewClient creates a new LLM client based on the provider
 func NewClient(provider string, cfg *config.Config) (Client, error) {
 	switch provider {
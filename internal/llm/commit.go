@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CommitOptions controls the format of generated commit messages
+type CommitOptions struct {
+	Style            string // "conventional" or "plain"
+	MaxSubjectLength int
+	BodyWrap         int
+}
+
+// GenerateCommitMessage asks the model for a commit message describing the staged diff
+func GenerateCommitMessage(ctx context.Context, client Client, diff string, stat string, opts CommitOptions, model string) (string, error) {
+	var styleRules string
+	switch opts.Style {
+	case "", "conventional":
+		styleRules = `Use the Conventional Commits format: "<type>(<optional scope>): <description>".
+Valid types are feat, fix, docs, style, refactor, perf, test, build, ci and chore.
+Mark breaking changes with "!" after the type and a "BREAKING CHANGE:" footer.`
+	case "plain":
+		styleRules = "Write the subject as a short imperative sentence, capitalized, without a trailing period."
+	default:
+		return "", fmt.Errorf("unknown commit message style: %s", opts.Style)
+	}
+
+	systemPrompt := fmt.Sprintf(`You write git commit messages for staged changes.
+%s
+The subject line must be at most %d characters and written in the imperative mood.
+If the change needs explanation, add a blank line and a body describing what changed and why, wrapped at %d columns.
+Reply with the commit message only: no code fences, quotes or commentary.`,
+		styleRules, opts.MaxSubjectLength, opts.BodyWrap)
+
+	prompt := fmt.Sprintf(`Write a commit message for these staged changes.
+
+Summary:
+%s
+Diff:
+%s`, stat, truncateDiff(diff))
+
+	message, err := client.Complete(ctx, systemPrompt, prompt, model)
+	if err != nil {
+		return "", err
+	}
+
+	message = FormatCommitMessage(message, opts.BodyWrap)
+	if message == "" {
+		return "", fmt.Errorf("model returned an empty commit message")
+	}
+	return message, nil
+}
+
+// FormatCommitMessage cleans up a generated commit message: it strips code
+// fences and surrounding quotes, separates subject and body with a blank
+// line, and wraps body paragraphs at width columns
+func FormatCommitMessage(message string, width int) string {
+	message = strings.TrimSpace(message)
+	message = strings.TrimPrefix(message, "```text")
+	message = strings.TrimPrefix(message, "```")
+	message = strings.TrimSuffix(message, "```")
+	message = strings.Trim(strings.TrimSpace(message), "\"`")
+
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return ""
+	}
+
+	subject := strings.TrimSpace(lines[0])
+	body := strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	if body == "" {
+		return subject + "\n"
+	}
+
+	return subject + "\n\n" + WrapText(body, width) + "\n"
+}
+
+// WrapText wraps each paragraph of text at width columns. List items keep a
+// hanging indent, and lines that look preformatted are left alone.
+func WrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(line) <= width || strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			out = append(out, strings.TrimRight(line, " "))
+			continue
+		}
+
+		indent := ""
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			indent = "  "
+		}
+
+		current := ""
+		for _, word := range strings.Fields(trimmed) {
+			switch {
+			case current == "":
+				current = word
+			case len(current)+1+len(word) > width:
+				out = append(out, current)
+				current = indent + word
+			default:
+				current += " " + word
+			}
+		}
+		out = append(out, current)
+	}
+
+	return strings.Join(out, "\n")
+}
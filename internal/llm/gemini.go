@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/EricBriscoe/llm-tool/internal/config"
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -228,73 +230,93 @@ func (c *GeminiClient) StreamResponse(ctx context.Context, prompt string, model
 }
 
 // ReviewCodeDiff reviews a git diff using the Gemini API
-func (c *GeminiClient) ReviewCodeDiff(ctx context.Context, diff string, model string) error {
+func (c *GeminiClient) ReviewCodeDiff(ctx context.Context, review ReviewRequest, model string) (string, error) {
 	if model == "" {
 		model = c.model
 	}
 
-	prompt := fmt.Sprintf(`Review this git diff and provide actionable feedback:
-
-%s
-
-Please analyze:
-1. Code quality issues
-2. Potential bugs
-3. Security concerns
-4. Performance considerations
-5. Suggested improvements
-`, diff)
+	prompt := buildReviewPrompt(review)
 
 	genModel := c.client.GenerativeModel(model)
 	genModel.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text("You are a helpful code reviewer. Provide clear, concise, and constructive feedback on git diffs.")},
+		Parts: []genai.Part{genai.Text(buildReviewSystemPrompt(review.Guidelines))},
 	}
 
 	resp, err := genModel.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
-		return fmt.Errorf("failed to generate content: %w", err)
+		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", fmt.Errorf("no response from Gemini API")
+	}
+
+	var result strings.Builder
 	fmt.Print("\n=== Code Review ===\n")
 	for _, part := range resp.Candidates[0].Content.Parts {
 		if text, ok := part.(genai.Text); ok {
 			fmt.Print(string(text))
+			result.WriteString(string(text))
 		}
 	}
 	fmt.Println("\n=== End of Review ===")
-	return nil
+	return result.String(), nil
 }
 
 // RefactorFile refactors a file based on user instructions using the Gemini API
 func (c *GeminiClient) RefactorFile(ctx context.Context, filename string, content string, instructions string, model string) (string, error) {
+	return refactorFile(ctx, c, filename, content, instructions, model)
+}
+
+// Complete returns a single non-streaming response to prompt using the Gemini API
+func (c *GeminiClient) Complete(ctx context.Context, systemPrompt string, prompt string, model string) (string, error) {
 	if model == "" {
 		model = c.model
 	}
 
-	prompt := fmt.Sprintf(`Refactor the following file based on these instructions:
-
-Instructions:
-%s
-
-Filename: %s
-
-Content:
-%s
+	genModel := c.client.GenerativeModel(model)
+	genModel.SystemInstruction = &genai.Content{
+		Parts: []genai.Part{genai.Text(systemPrompt)},
+	}
+	return generateText(ctx, genModel, prompt)
+}
 
-Please provide the complete refactored file content, maintaining the original functionality unless the instructions 
-specifically require changes. Keep all imports and package declarations.`, 
-		instructions, filename, content)
+// CompleteJSON asks for JSON matching schema using the Gemini API's JSON
+// mode. Schemas Gemini can't express, such as those combining schemas with
+// anyOf, are given in the system prompt only.
+func (c *GeminiClient) CompleteJSON(ctx context.Context, systemPrompt string, prompt string, schema json.RawMessage, model string) (string, error) {
+	if model == "" {
+		model = c.model
+	}
 
+	parsed, err := parseJSONSchema(schema)
+	if err != nil {
+		return "", err
+	}
 	genModel := c.client.GenerativeModel(model)
 	genModel.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text("You are an expert software engineer tasked with refactoring code files. Provide only the refactored code without explanations unless explicitly asked.")},
+		Parts: []genai.Part{genai.Text(jsonSystemPrompt(systemPrompt, schema))},
+	}
+	genModel.ResponseMIMEType = "application/json"
+	if len(parsed.AnyOf) == 0 && len(parsed.OneOf) == 0 && len(parsed.AllOf) == 0 && parsed.Not == nil {
+		if responseSchema, err := toGenAISchema(parsed); err == nil {
+			genModel.ResponseSchema = responseSchema
+		}
 	}
+	return generateText(ctx, genModel, prompt)
+}
 
+// generateText sends prompt to genModel and returns the text of the response
+func generateText(ctx context.Context, genModel *genai.GenerativeModel, prompt string) (string, error) {
 	resp, err := genModel.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", fmt.Errorf("no response from Gemini API")
+	}
+
 	var result strings.Builder
 	for _, part := range resp.Candidates[0].Content.Parts {
 		if text, ok := part.(genai.Text); ok {
@@ -302,9 +324,208 @@ specifically require changes. Keep all imports and package declarations.`,
 		}
 	}
 
+	if resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+		return result.String(), ErrOutputTruncated
+	}
+
 	return result.String(), nil
 }
 
+// Chat holds a conversation with tools using the Gemini API, streaming the
+// reply. It is separate from the history kept for StreamResponse.
+func (c *GeminiClient) Chat(ctx context.Context, chat ChatRequest, onEvent func(StreamEvent)) (Message, error) {
+	if err := validateChatRequest(chat); err != nil {
+		return Message{}, err
+	}
+	model := chat.Model
+	if model == "" {
+		model = c.model
+	}
+
+	genModel := c.client.GenerativeModel(model)
+	if len(chat.Tools) > 0 {
+		tool := &genai.Tool{}
+		for _, t := range chat.Tools {
+			schema, err := parseJSONSchema(t.schema())
+			if err != nil {
+				return Message{}, fmt.Errorf("tool %s: %w", t.Name, err)
+			}
+			parameters, err := toGenAISchema(schema)
+			if err != nil {
+				return Message{}, fmt.Errorf("tool %s: %w", t.Name, err)
+			}
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, &genai.FunctionDeclaration{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  parameters,
+			})
+		}
+		genModel.Tools = []*genai.Tool{tool}
+	}
+
+	var system []genai.Part
+	var contents []*genai.Content
+	for _, message := range chat.Messages {
+		switch message.Role {
+		case RoleSystem:
+			system = append(system, genai.Text(message.Content))
+		case RoleUser:
+			contents = appendGenAIContent(contents, "user", genai.Text(message.Content))
+		case RoleAssistant:
+			var parts []genai.Part
+			if message.Content != "" {
+				parts = append(parts, genai.Text(message.Content))
+			}
+			for _, call := range message.ToolCalls {
+				var args map[string]any
+				if err := json.Unmarshal(call.Arguments, &args); err != nil {
+					return Message{}, fmt.Errorf("invalid arguments in call to %s: %w", call.Name, err)
+				}
+				parts = append(parts, genai.FunctionCall{Name: call.Name, Args: args})
+			}
+			contents = appendGenAIContent(contents, "model", parts...)
+		case RoleTool:
+			// Gemini matches results to calls by name rather than by ID
+			if message.Name == "" {
+				return Message{}, fmt.Errorf("tool result %s has no tool name", message.ToolCallID)
+			}
+			contents = appendGenAIContent(contents, "user", genai.FunctionResponse{
+				Name:     message.Name,
+				Response: map[string]any{"content": message.Content},
+			})
+		}
+	}
+	if len(system) > 0 {
+		genModel.SystemInstruction = &genai.Content{Parts: system}
+	}
+	if len(contents) == 0 || contents[len(contents)-1].Role != "user" {
+		return Message{}, fmt.Errorf("the last message must be from the user or a tool result")
+	}
+
+	cs := genModel.StartChat()
+	cs.History = contents[:len(contents)-1]
+	iter := cs.SendMessageStream(ctx, contents[len(contents)-1].Parts...)
+
+	reply := Message{Role: RoleAssistant}
+	var content strings.Builder
+	truncated := false
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return Message{}, fmt.Errorf("error receiving response: %w", err)
+		}
+		if len(resp.Candidates) == 0 {
+			continue
+		}
+
+		candidate := resp.Candidates[0]
+		if candidate.FinishReason == genai.FinishReasonMaxTokens {
+			truncated = true
+		}
+		if candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			switch part := part.(type) {
+			case genai.Text:
+				content.WriteString(string(part))
+				emit(onEvent, StreamEvent{Text: string(part)})
+			case genai.FunctionCall:
+				args, err := json.Marshal(part.Args)
+				if err != nil {
+					return Message{}, fmt.Errorf("invalid arguments in call to %s: %w", part.Name, err)
+				}
+				// Gemini doesn't identify calls, so number them within the chat
+				id := fmt.Sprintf("call_%d_%d", len(chat.Messages), len(reply.ToolCalls))
+				toolCall, err := newToolCall(id, part.Name, string(args))
+				if err != nil {
+					return Message{}, err
+				}
+				reply.ToolCalls = append(reply.ToolCalls, toolCall)
+				emit(onEvent, StreamEvent{ToolCall: &toolCall})
+			}
+		}
+	}
+
+	reply.Content = content.String()
+	if truncated {
+		return reply, ErrOutputTruncated
+	}
+	return reply, nil
+}
+
+// appendGenAIContent adds parts to contents, merging them into the last
+// content if it has the same role, since Gemini expects the roles to alternate
+func appendGenAIContent(contents []*genai.Content, role string, parts ...genai.Part) []*genai.Content {
+	if len(contents) > 0 && contents[len(contents)-1].Role == role {
+		last := contents[len(contents)-1]
+		last.Parts = append(last.Parts, parts...)
+		return contents
+	}
+	return append(contents, &genai.Content{Role: role, Parts: parts})
+}
+
+// toGenAISchema translates a JSON schema into Gemini's schema type
+func toGenAISchema(schema *jsonSchema) (*genai.Schema, error) {
+	name, nullable, err := schema.nonNullType()
+	if err != nil {
+		return nil, err
+	}
+
+	converted := &genai.Schema{
+		Format:      schema.Format,
+		Description: schema.Description,
+		Nullable:    nullable,
+		Required:    schema.Required,
+	}
+	switch name {
+	case "string":
+		converted.Type = genai.TypeString
+	case "number":
+		converted.Type = genai.TypeNumber
+	case "integer":
+		converted.Type = genai.TypeInteger
+	case "boolean":
+		converted.Type = genai.TypeBoolean
+	case "array":
+		converted.Type = genai.TypeArray
+	case "object":
+		converted.Type = genai.TypeObject
+	default:
+		return nil, fmt.Errorf("unsupported schema type %q", name)
+	}
+
+	// Gemini only supports enums of strings. Other enums are left out of the
+	// request and enforced when the response is validated.
+	if name == "string" {
+		for _, value := range schema.Enum {
+			if text, ok := value.(string); ok {
+				converted.Enum = append(converted.Enum, text)
+			}
+		}
+		if len(converted.Enum) > 0 {
+			converted.Format = "enum"
+		}
+	}
+	if schema.Items != nil {
+		if converted.Items, err = toGenAISchema(schema.Items); err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+	}
+	if len(schema.Properties) > 0 {
+		converted.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+		for property, propertySchema := range schema.Properties {
+			if converted.Properties[property], err = toGenAISchema(propertySchema); err != nil {
+				return nil, fmt.Errorf("property %s: %w", property, err)
+			}
+		}
+	}
+	return converted, nil
+}
+
 // ClearChatHistory clears the stored conversation history
 func (c *GeminiClient) ClearChatHistory() error {
 	if _, err := os.Stat(c.historyPath); os.IsNotExist(err) {
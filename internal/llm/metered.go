@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MeteredClient wraps a Client and keeps a rough count of the tokens sent to
+// and received from the model by RefactorFile, Complete, CompleteJSON and Chat
+type MeteredClient struct {
+	Client
+	mu     sync.Mutex
+	tokens int
+}
+
+// NewMeteredClient wraps client with token accounting
+func NewMeteredClient(client Client) *MeteredClient {
+	return &MeteredClient{Client: client}
+}
+
+// RefactorFile refactors a file with the wrapped client and counts its tokens
+func (m *MeteredClient) RefactorFile(ctx context.Context, filename string, content string, instructions string, model string) (string, error) {
+	result, err := m.Client.RefactorFile(ctx, filename, content, instructions, model)
+	m.add(len(refactorSystemPrompt) + len(buildRefactorPrompt(filename, content, instructions)) + len(result))
+	return result, err
+}
+
+// Complete runs a completion with the wrapped client and counts its tokens
+func (m *MeteredClient) Complete(ctx context.Context, systemPrompt string, prompt string, model string) (string, error) {
+	result, err := m.Client.Complete(ctx, systemPrompt, prompt, model)
+	m.add(len(systemPrompt) + len(prompt) + len(result))
+	return result, err
+}
+
+// CompleteJSON runs a structured completion with the wrapped client and counts
+// its tokens
+func (m *MeteredClient) CompleteJSON(ctx context.Context, systemPrompt string, prompt string, schema json.RawMessage, model string) (string, error) {
+	result, err := m.Client.CompleteJSON(ctx, systemPrompt, prompt, schema, model)
+	m.add(len(systemPrompt) + len(prompt) + len(schema) + len(result))
+	return result, err
+}
+
+// Chat runs a conversation with the wrapped client and counts its tokens
+func (m *MeteredClient) Chat(ctx context.Context, req ChatRequest, onEvent func(StreamEvent)) (Message, error) {
+	reply, err := m.Client.Chat(ctx, req, onEvent)
+	chars := 0
+	for _, message := range append(append([]Message(nil), req.Messages...), reply) {
+		chars += len(message.Content)
+		for _, call := range message.ToolCalls {
+			chars += len(call.Name) + len(call.Arguments)
+		}
+	}
+	for _, tool := range req.Tools {
+		chars += len(tool.Name) + len(tool.Description) + len(tool.Parameters)
+	}
+	m.add(chars)
+	return reply, err
+}
+
+// Tokens returns the estimated number of tokens used so far
+func (m *MeteredClient) Tokens() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens
+}
+
+// add records chars characters of traffic, at roughly four characters per token
+func (m *MeteredClient) add(chars int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens += EstimateTokens(chars)
+}
+
+// EstimateTokens approximates the number of tokens in chars characters of text
+func EstimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
@@ -0,0 +1,259 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+)
+
+// Markers delimiting a file in multi-file prompts and responses
+const (
+	fileStartMarker  = "=== FILE: "
+	fileEndMarker    = "=== END FILE ==="
+	fileDeleteMarker = "=== DELETE: "
+)
+
+const multiFileSystemPrompt = `You are an expert software engineer who makes consistent changes across several files at once.
+You are given the files to edit and related files for reference. Apply the instructions across all of them, keeping names, signatures and call sites consistent.
+Related files may be edited too when the instructions require it, for example to update references to a renamed identifier.
+
+Return only the files you change, each in exactly this format:
+
+=== FILE: path/of/the/file ===
+%s
+=== END FILE ===
+
+To create a new file, use the same format with the complete content of the new file, at a path relative to the other files.
+To delete a file, output a single line instead:
+
+=== DELETE: path/of/the/file ===
+
+Use the paths exactly as given. Do not wrap files in Markdown code fences and do not add explanations outside the file sections.`
+
+const multiFileWholeFormat = `the complete new content of the file`
+
+const multiFileSearchReplaceFormat = `one or more search/replace blocks:
+<<<<<<< SEARCH
+lines copied exactly from the current file
+=======
+the lines that replace them
+>>>>>>> REPLACE
+Copy the SEARCH lines character for character, including indentation, with enough surrounding lines to make each one unique in the file.`
+
+// SourceFile is a file sent to the model in a multi-file edit
+type SourceFile struct {
+	Name    string // Path shown to the model
+	Content string
+}
+
+// MultiFileRequest describes an edit that spans several files
+type MultiFileRequest struct {
+	Instructions string
+	Files        []SourceFile // Files to edit
+	Related      []SourceFile // Files sent for reference, which may also be edited
+	Create       []string     // Names of new files the model is asked to write
+	Mode         string       // EditModeSearchReplace, or "" for whole files
+}
+
+// FileChange is a change to one file returned by EditFiles
+type FileChange struct {
+	Name     string
+	Content  string // New content of the file; empty for a deletion
+	IsNew    bool
+	IsDelete bool
+}
+
+// EditFiles sends all the files of req to the model in one request and
+// returns the changes it made, which may include new and deleted files. The
+// edit succeeds or fails as a whole: if any file can't be parsed or patched,
+// no changes are returned.
+func EditFiles(ctx context.Context, client Client, req MultiFileRequest, model string) ([]FileChange, error) {
+	format := multiFileWholeFormat
+	switch req.Mode {
+	case "":
+	case EditModeSearchReplace:
+		format = multiFileSearchReplaceFormat
+	default:
+		return nil, fmt.Errorf("edit mode %s is not supported for multi-file edits", req.Mode)
+	}
+
+	response, err := client.Complete(ctx, fmt.Sprintf(multiFileSystemPrompt, format), buildMultiFilePrompt(req), model)
+	if errors.Is(err, ErrOutputTruncated) {
+		return nil, fmt.Errorf("%w; edit fewer files at once or use --mode search-replace", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := parseFileSections(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse files from model: %w", err)
+	}
+
+	originals := make(map[string]string)
+	for _, file := range append(append([]SourceFile(nil), req.Files...), req.Related...) {
+		originals[file.Name] = file.Content
+	}
+
+	var changes []FileChange
+	var problems []string
+	for _, section := range sections {
+		original, ok := originals[section.name]
+		switch {
+		case section.delete && !ok:
+			problems = append(problems, fmt.Sprintf("%s: can't delete a file that wasn't sent to the model", section.name))
+			continue
+		case section.delete:
+			changes = append(changes, FileChange{Name: section.name, IsDelete: true})
+			continue
+		case !ok:
+			// A new file is always returned in full
+			if err := validateNewFileName(section.name); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", section.name, err))
+				continue
+			}
+			content, err := FinishRefactor(section.name, section.body)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", section.name, err))
+				continue
+			}
+			changes = append(changes, FileChange{Name: section.name, Content: content, IsNew: true})
+			continue
+		}
+
+		if req.Mode == EditModeSearchReplace {
+			edits, err := fileutil.ParseSearchReplace(section.body)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", section.name, err))
+				continue
+			}
+			patched, failures := fileutil.ApplySearchReplace(original, edits)
+			if len(failures) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: %d edits could not be applied:\n%s", section.name, len(failures), FormatPatchFailures(failures)))
+				continue
+			}
+			changes = append(changes, FileChange{Name: section.name, Content: patched})
+			continue
+		}
+
+		content, err := FinishRefactor(section.name, section.body)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", section.name, err))
+			continue
+		}
+		changes = append(changes, FileChange{Name: section.name, Content: content})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("the model's edits could not be applied as a whole:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return changes, nil
+}
+
+// validateNewFileName rejects paths for new files that aren't relative or
+// that point into a .git directory. Callers check that the path stays inside
+// the directory the edit is staged against.
+func validateNewFileName(name string) error {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return fmt.Errorf("new files must have a relative path")
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if strings.EqualFold(part, ".git") {
+			return fmt.Errorf("new files can't be inside a .git directory")
+		}
+	}
+	return nil
+}
+
+// buildMultiFilePrompt returns the user prompt for a multi-file edit
+func buildMultiFilePrompt(req MultiFileRequest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Edit the following files based on these instructions:\n\nInstructions:\n%s\n\n", req.Instructions)
+	if len(req.Files) > 0 {
+		sb.WriteString("Files to edit:\n\n")
+		for _, file := range req.Files {
+			writeFileSection(&sb, file)
+		}
+	}
+	if len(req.Related) > 0 {
+		sb.WriteString("Related files:\n\n")
+		for _, file := range req.Related {
+			writeFileSection(&sb, file)
+		}
+	}
+	if len(req.Create) > 0 {
+		sb.WriteString("Create these new files:\n")
+		for _, name := range req.Create {
+			fmt.Fprintf(&sb, "- %s\n", name)
+		}
+	}
+	return sb.String()
+}
+
+// writeFileSection writes a file between multi-file markers
+func writeFileSection(sb *strings.Builder, file SourceFile) {
+	sb.WriteString(fileStartMarker + file.Name + " ===\n")
+	sb.WriteString(file.Content)
+	if !strings.HasSuffix(file.Content, "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fileEndMarker + "\n\n")
+}
+
+// fileSection is a file returned in a multi-file response
+type fileSection struct {
+	name   string
+	body   string
+	delete bool
+}
+
+// parseFileSections splits a multi-file response into its file sections
+func parseFileSections(response string) ([]fileSection, error) {
+	lines := strings.Split(strings.ReplaceAll(response, "\r\n", "\n"), "\n")
+
+	var sections []fileSection
+	seen := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		marker := fileStartMarker
+		if strings.HasPrefix(line, fileDeleteMarker) {
+			marker = fileDeleteMarker
+		} else if !strings.HasPrefix(line, fileStartMarker) {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, marker), "==="))
+		if name == "" {
+			return nil, fmt.Errorf("file section without a path on line %d", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is returned more than once", name)
+		}
+		seen[name] = true
+
+		if marker == fileDeleteMarker {
+			sections = append(sections, fileSection{name: name, delete: true})
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != fileEndMarker {
+			end++
+		}
+		if end == len(lines) {
+			return nil, fmt.Errorf("file section for %s is not closed with %q", name, fileEndMarker)
+		}
+
+		sections = append(sections, fileSection{name: name, body: strings.Join(lines[i+1:end], "\n") + "\n"})
+		i = end
+	}
+
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no file sections found in response")
+	}
+	return sections, nil
+}
@@ -0,0 +1,27 @@
+package llm
+
+import "testing"
+
+func TestValidateNewFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "main.go"},
+		{name: "pkg/util/util.go"},
+		{name: "../sibling/file.go"},
+		{name: ".github/workflows/ci.yml"},
+		{name: "/etc/passwd", wantErr: true},
+		{name: ".git/hooks/pre-commit", wantErr: true},
+		{name: "sub/.git/config", wantErr: true},
+		{name: ".GIT/config", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateNewFileName(tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("validateNewFileName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/EricBriscoe/llm-tool/internal/config"
 	"github.com/sashabaranov/go-openai"
@@ -67,29 +69,19 @@ func (c *OpenAIClient) StreamResponse(ctx context.Context, prompt string, model
 	}
 }
 
-func (c *OpenAIClient) ReviewCodeDiff(ctx context.Context, diff string, model string) error {
+func (c *OpenAIClient) ReviewCodeDiff(ctx context.Context, review ReviewRequest, model string) (string, error) {
 	if model == "" {
 		model = c.model
 	}
 
-	prompt := fmt.Sprintf(`Review this git diff and provide actionable feedback:
-
-%s
-
-Please analyze:
-1. Code quality issues
-2. Potential bugs
-3. Security concerns
-4. Performance considerations
-5. Suggested improvements
-`, diff)
+	prompt := buildReviewPrompt(review)
 
 	req := openai.ChatCompletionRequest{
 		Model: model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are a helpful code reviewer. Provide clear, concise, and constructive feedback on git diffs.",
+				Content: buildReviewSystemPrompt(review.Guidelines),
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
@@ -101,51 +93,76 @@ Please analyze:
 
 	stream, err := c.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
-		return fmt.Errorf("error creating stream: %w", err)
+		return "", fmt.Errorf("error creating stream: %w", err)
 	}
 	defer stream.Close()
 
+	var result strings.Builder
 	fmt.Print("\n=== Code Review ===\n")
 	for {
 		response, err := stream.Recv()
 		if err != nil {
 			if err == io.EOF {
 				fmt.Println("\n=== End of Review ===")
-				return nil
+				return result.String(), nil
 			}
-			return fmt.Errorf("stream error: %w", err)
+			return "", fmt.Errorf("stream error: %w", err)
 		}
 
+		if len(response.Choices) == 0 {
+			continue
+		}
 		fmt.Print(response.Choices[0].Delta.Content)
+		result.WriteString(response.Choices[0].Delta.Content)
 	}
 }
 
 // RefactorFile refactors a file based on user instructions using the OpenAI API
 func (c *OpenAIClient) RefactorFile(ctx context.Context, filename string, content string, instructions string, model string) (string, error) {
+	return refactorFile(ctx, c, filename, content, instructions, model)
+}
+
+// Complete returns a single non-streaming response to prompt using the OpenAI API
+func (c *OpenAIClient) Complete(ctx context.Context, systemPrompt string, prompt string, model string) (string, error) {
 	if model == "" {
 		model = c.model
 	}
 
-	prompt := fmt.Sprintf(`Refactor the following file based on these instructions:
-
-Instructions:
-%s
-
-Filename: %s
+	req := openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+	}
 
-Content:
-%s
+	return c.complete(ctx, req)
+}
 
-Please provide the complete refactored file content, maintaining the original functionality unless the instructions 
-specifically require changes. Keep all imports and package declarations.`, 
-		instructions, filename, content)
+// CompleteJSON asks for JSON matching schema using the OpenAI API's
+// structured output mode. That mode needs an object at the top level, so
+// other schemas are given in the system prompt instead.
+func (c *OpenAIClient) CompleteJSON(ctx context.Context, systemPrompt string, prompt string, schema json.RawMessage, model string) (string, error) {
+	if model == "" {
+		model = c.model
+	}
 
+	parsed, err := parseJSONSchema(schema)
+	if err != nil {
+		return "", err
+	}
 	req := openai.ChatCompletionRequest{
 		Model: model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an expert software engineer tasked with refactoring code files. Provide only the refactored code without explanations unless explicitly asked.",
+				Content: jsonSystemPrompt(systemPrompt, schema),
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
@@ -153,7 +170,23 @@ specifically require changes. Keep all imports and package declarations.`,
 			},
 		},
 	}
+	if name, _, err := parsed.nonNullType(); err == nil && name == "object" {
+		// Strict mode would reject schemas with optional properties, which
+		// are validated locally instead
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "response",
+				Schema: schema,
+			},
+		}
+	}
+
+	return c.complete(ctx, req)
+}
 
+// complete sends a non-streaming request and returns the first choice
+func (c *OpenAIClient) complete(ctx context.Context, req openai.ChatCompletionRequest) (string, error) {
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("API error: %w", err)
@@ -163,9 +196,127 @@ specifically require changes. Keep all imports and package declarations.`,
 		return "", fmt.Errorf("no response from OpenAI API")
 	}
 
+	if resp.Choices[0].FinishReason == openai.FinishReasonLength {
+		return resp.Choices[0].Message.Content, ErrOutputTruncated
+	}
+
 	return resp.Choices[0].Message.Content, nil
 }
 
+// Chat holds a conversation with tools using the OpenAI API, streaming the reply
+func (c *OpenAIClient) Chat(ctx context.Context, chat ChatRequest, onEvent func(StreamEvent)) (Message, error) {
+	if err := validateChatRequest(chat); err != nil {
+		return Message{}, err
+	}
+	model := chat.Model
+	if model == "" {
+		model = c.model
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:  model,
+		Stream: true,
+	}
+	for _, message := range chat.Messages {
+		req.Messages = append(req.Messages, toOpenAIMessage(message))
+	}
+	for _, tool := range chat.Tools {
+		req.Tools = append(req.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.schema(),
+			},
+		})
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return Message{}, fmt.Errorf("error creating stream: %w", err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	var calls []openai.ToolCall // Assembled from fragments, by index
+	var finishReason openai.FinishReason
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Message{}, fmt.Errorf("stream error: %w", err)
+		}
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		choice := response.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+			emit(onEvent, StreamEvent{Text: choice.Delta.Content})
+		}
+		for _, fragment := range choice.Delta.ToolCalls {
+			i := len(calls)
+			if fragment.Index != nil {
+				i = *fragment.Index
+			}
+			for len(calls) <= i {
+				calls = append(calls, openai.ToolCall{})
+			}
+			if fragment.ID != "" {
+				calls[i].ID = fragment.ID
+			}
+			calls[i].Function.Name += fragment.Function.Name
+			calls[i].Function.Arguments += fragment.Function.Arguments
+		}
+	}
+
+	reply := Message{Role: RoleAssistant, Content: content.String()}
+	truncated := finishReason == openai.FinishReasonLength
+	for _, call := range calls {
+		toolCall, err := newToolCall(call.ID, call.Function.Name, call.Function.Arguments)
+		if err != nil {
+			if truncated {
+				// The call was cut off with the rest of the output
+				continue
+			}
+			return Message{}, err
+		}
+		reply.ToolCalls = append(reply.ToolCalls, toolCall)
+		emit(onEvent, StreamEvent{ToolCall: &toolCall})
+	}
+
+	if truncated {
+		return reply, ErrOutputTruncated
+	}
+	return reply, nil
+}
+
+// toOpenAIMessage translates a chat message for the OpenAI API
+func toOpenAIMessage(message Message) openai.ChatCompletionMessage {
+	converted := openai.ChatCompletionMessage{
+		Role:       message.Role,
+		Content:    message.Content,
+		ToolCallID: message.ToolCallID,
+	}
+	for _, call := range message.ToolCalls {
+		converted.ToolCalls = append(converted.ToolCalls, openai.ToolCall{
+			ID:   call.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      call.Name,
+				Arguments: string(call.Arguments),
+			},
+		})
+	}
+	return converted
+}
+
 // ClearChatHistory is a placeholder for OpenAI as we don't currently store chat history
 func (c *OpenAIClient) ClearChatHistory() error {
 	// OpenAI client doesn't maintain history yet, so this is a no-op
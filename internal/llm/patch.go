@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/fileutil"
+)
+
+// Edit modes for PatchFile
+const (
+	EditModeSearchReplace = "search-replace"
+	EditModeUnifiedDiff   = "udiff"
+)
+
+const searchReplaceSystemPrompt = `You are an expert software engineer who edits code files by returning search/replace blocks.
+For each change, output a block in exactly this format:
+
+<<<<<<< SEARCH
+lines copied exactly from the current file
+=======
+the lines that replace them
+>>>>>>> REPLACE
+
+Copy the SEARCH lines character for character, including indentation, and include enough surrounding lines to make each one unique in the file.
+Keep blocks small and list them in file order. Do not return the whole file and do not add explanations.`
+
+const unifiedDiffSystemPrompt = `You are an expert software engineer who edits code files by returning a unified diff.
+Output a single unified diff for the file, with "@@ -a,b +c,d @@" hunk headers, 3 lines of unchanged context around each change,
+" " for context lines, "-" for removed lines and "+" for added lines. Copy context and removed lines exactly from the current file.
+Do not return the whole file and do not add explanations.`
+
+// PatchFile asks the model for targeted edits to content in the given mode
+// and applies them. Edits that could not be applied are returned as
+// failures alongside the partially patched content.
+func PatchFile(ctx context.Context, client Client, filename string, content string, instructions string, mode string, model string) (string, []fileutil.PatchFailure, error) {
+	systemPrompt := searchReplaceSystemPrompt
+	if mode == EditModeUnifiedDiff {
+		systemPrompt = unifiedDiffSystemPrompt
+	} else if mode != EditModeSearchReplace {
+		return "", nil, fmt.Errorf("unknown edit mode: %s", mode)
+	}
+
+	prompt := fmt.Sprintf(`Edit the following file based on these instructions:
+
+Instructions:
+%s
+
+Filename: %s
+
+Content:
+%s`, instructions, filename, content)
+
+	response, err := client.Complete(ctx, systemPrompt, prompt, model)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if mode == EditModeUnifiedDiff {
+		patched, failures, err := fileutil.ApplyUnifiedDiff(content, stripMarkdownFence(response))
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse diff from model: %w", err)
+		}
+		return patched, failures, nil
+	}
+
+	edits, err := fileutil.ParseSearchReplace(response)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not parse edits from model: %w", err)
+	}
+	patched, failures := fileutil.ApplySearchReplace(content, edits)
+	return patched, failures, nil
+}
+
+// FormatPatchFailures describes failed edits for display to the user
+func FormatPatchFailures(failures []fileutil.PatchFailure) string {
+	var sb strings.Builder
+	for _, f := range failures {
+		fmt.Fprintf(&sb, "  %s\n", f)
+		for _, line := range strings.Split(strings.TrimRight(f.Text, "\n"), "\n") {
+			fmt.Fprintf(&sb, "    | %s\n", line)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EricBriscoe/llm-tool/internal/config"
+)
+
+const reviewSystemPrompt = "You are a helpful code reviewer. Provide clear, concise, and constructive feedback on git diffs."
+
+// maxPromptDiffChars caps how much of a diff is sent when summarizing changes
+const maxPromptDiffChars = 60000
+
+const refactorSystemPrompt = "You are an expert software engineer tasked with refactoring code files. Provide only the refactored code without explanations unless explicitly asked."
+
+// buildReviewSystemPrompt returns the reviewer system prompt, extended with
+// project guidelines when they are provided
+func buildReviewSystemPrompt(guidelines string) string {
+	if strings.TrimSpace(guidelines) == "" {
+		return reviewSystemPrompt
+	}
+
+	return reviewSystemPrompt + `
+
+This project has its own review guidelines. Apply them in addition to the general checklist, and call out any violations explicitly:
+
+` + guidelines
+}
+
+// buildReviewPrompt returns the user prompt asking for a review of the diff
+func buildReviewPrompt(review ReviewRequest) string {
+	prompt := fmt.Sprintf(`Review this git diff and provide actionable feedback:
+
+%s
+
+Please analyze:
+1. Code quality issues
+2. Potential bugs
+3. Security concerns
+4. Performance considerations
+5. Suggested improvements
+`, review.Diff)
+
+	if strings.TrimSpace(review.Context) != "" {
+		prompt += fmt.Sprintf(`
+For reference, here is the post-change source surrounding the modified code, with line numbers.
+Focus your review on the changes in the diff, using this context to spot bugs that depend on code outside the hunks:
+
+%s`, review.Context)
+	}
+
+	if review.RequireSeverity {
+		prompt += `
+Finish your review with a final line of the form "Severity: <level>", where <level> is the most serious issue found:
+none, low, medium, high or critical. Use high for bugs or security issues that should block the change.
+`
+	}
+
+	return prompt
+}
+
+// FormatReviewGuidelines renders project guidelines and the rules matching
+// the changed files as text for the reviewer system prompt
+func FormatReviewGuidelines(g *config.ReviewGuidelines, changedFiles []string) string {
+	if g == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if text := strings.TrimSpace(g.Guidelines); text != "" {
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	rules := g.MatchingRules(changedFiles)
+	if len(rules) > 0 {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("Path-specific rules (apply only to files matching the pattern):\n")
+		for _, rule := range rules {
+			fmt.Fprintf(&sb, "- %s: %s\n", rule.Path, strings.TrimSpace(rule.Instructions))
+		}
+	}
+
+	return sb.String()
+}
+
+// buildRefactorPrompt returns the user prompt asking for a whole-file refactor
+func buildRefactorPrompt(filename string, content string, instructions string) string {
+	return fmt.Sprintf(`Refactor the following file based on these instructions:
+
+Instructions:
+%s
+
+Filename: %s
+
+Content:
+%s
+
+Please provide the complete refactored file content, maintaining the original functionality unless the instructions 
+specifically require changes. Keep all imports and package declarations.`,
+		instructions, filename, content)
+}
+
+// truncateDiff shortens diff to maxPromptDiffChars, marking where it was cut
+func truncateDiff(diff string) string {
+	if len(diff) <= maxPromptDiffChars {
+		return diff
+	}
+	return diff[:maxPromptDiffChars] + "\n[diff truncated]\n"
+}
+
+// BuildFixInstructions extends the original edit instructions with the
+// failures from the previous attempt, asking the model to fix them
+func BuildFixInstructions(instructions string, failures string) string {
+	return fmt.Sprintf(`%s
+
+The file below is your previous attempt at these instructions. It fails to build or its tests fail with the following output:
+
+%s
+
+Fix these failures while still following the original instructions. Change only what is needed.`, instructions, failures)
+}
+
+// BuildFollowUpInstructions asks for further changes to a file that the
+// original instructions have already been applied to
+func BuildFollowUpInstructions(instructions string, followUp string) string {
+	return fmt.Sprintf(`The file below has already been edited according to these instructions:
+
+%s
+
+Make these additional changes to it:
+
+%s`, instructions, followUp)
+}
@@ -0,0 +1,242 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// fenceLanguages maps file extensions to the info strings models use on code fences
+var fenceLanguages = map[string][]string{
+	".go":   {"go", "golang"},
+	".py":   {"python", "py"},
+	".js":   {"javascript", "js"},
+	".jsx":  {"jsx", "javascript", "js"},
+	".ts":   {"typescript", "ts"},
+	".tsx":  {"tsx", "typescript", "ts"},
+	".rs":   {"rust", "rs"},
+	".java": {"java"},
+	".rb":   {"ruby", "rb"},
+	".sh":   {"bash", "sh", "shell"},
+	".yaml": {"yaml", "yml"},
+	".yml":  {"yaml", "yml"},
+	".json": {"json"},
+	".md":   {"markdown", "md"},
+	".sql":  {"sql"},
+	".c":    {"c"},
+	".h":    {"c", "cpp"},
+	".cpp":  {"cpp", "c++"},
+}
+
+// proseExtensions are file types where leading or trailing prose is legitimate content
+var proseExtensions = map[string]bool{
+	"":          true,
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".rst":      true,
+}
+
+// leadingChatter matches the sentences models put before the file body
+var leadingChatter = regexp.MustCompile(`(?i)^((here('s| is| are| you go)|sure|certainly|of course|okay|below (is|are)|the (following|refactored|updated|modified|complete)|i('ve| have| made)|this is the)\b|ok[,.!])`)
+
+// trailingChatter matches the sentences models put after the file body
+var trailingChatter = regexp.MustCompile(`(?i)^\**(this|these|i('ve| have)|the (changes|refactor|code|main)|note|notes|changes( made)?|explanation|key changes|summary|let me know)\**[\s:,]`)
+
+// fencedBlock is a Markdown code block found in a model response
+type fencedBlock struct {
+	Lang  string
+	Start int // Index of the opening fence line
+	End   int // Index of the closing fence line
+}
+
+// ExtractFileContent returns the file body from a model response to a
+// whole-file edit. It unwraps Markdown code fences and removes the prose
+// models tend to add around the code, such as "Here is the refactored file:".
+func ExtractFileContent(response string, filename string) string {
+	text := strings.ReplaceAll(response, "\r\n", "\n")
+	lines := strings.Split(strings.Trim(text, "\n"), "\n")
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	// A response wrapped in a single outer fence, which may itself contain
+	// fences (common for Markdown files)
+	if len(lines) >= 2 && isOpeningFence(lines[0]) && isClosingFence(lines[len(lines)-1]) {
+		blocks := findFencedBlocks(lines)
+		if len(blocks) == 1 || proseExtensions[ext] {
+			return joinLines(lines[1 : len(lines)-1])
+		}
+	}
+
+	if proseExtensions[ext] {
+		return joinLines(lines)
+	}
+
+	// Only take a block out of a response that starts with one, or with
+	// chatter; otherwise the fences belong to the file
+	first := strings.TrimSpace(lines[0])
+	if isOpeningFence(first) || leadingChatter.MatchString(first) {
+		if blocks := findFencedBlocks(lines); len(blocks) > 0 {
+			best := pickBlock(blocks, ext)
+			return joinLines(lines[best.Start+1 : best.End])
+		}
+	}
+
+	return joinLines(stripChatter(lines))
+}
+
+// ValidateFileContent reports an error if content does not look like a
+// complete file, so that partial or conversational output is never staged
+func ValidateFileContent(filename string, content string) error {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return fmt.Errorf("model returned an empty file")
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	if isOpeningFence(lines[0]) || isClosingFence(lines[len(lines)-1]) {
+		return fmt.Errorf("output still contains Markdown code fences")
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !proseExtensions[ext] && leadingChatter.MatchString(strings.TrimSpace(lines[0])) {
+		return fmt.Errorf("output starts with prose instead of file content: %q", lines[0])
+	}
+
+	switch ext {
+	case ".go":
+		if _, err := parser.ParseFile(token.NewFileSet(), filename, content, 0); err != nil {
+			return fmt.Errorf("output is not a complete Go file: %w", err)
+		}
+	case ".json":
+		if !json.Valid([]byte(content)) {
+			return fmt.Errorf("output is not valid JSON")
+		}
+	}
+
+	return nil
+}
+
+// refactorFile runs a whole-file refactor through client and cleans up the
+// response. If the output was truncated, the raw partial response is returned
+// with ErrOutputTruncated so that it can be passed to ContinueRefactor.
+func refactorFile(ctx context.Context, client Client, filename string, content string, instructions string, model string) (string, error) {
+	response, err := client.Complete(ctx, refactorSystemPrompt, buildRefactorPrompt(filename, content, instructions), model)
+	if err != nil {
+		return response, err
+	}
+	return FinishRefactor(filename, response)
+}
+
+// FinishRefactor extracts and validates the file body from a complete refactor response
+func FinishRefactor(filename string, response string) (string, error) {
+	refactored := ExtractFileContent(response, filename)
+	if err := ValidateFileContent(filename, refactored); err != nil {
+		return "", fmt.Errorf("refusing to stage model output: %w", err)
+	}
+	return refactored, nil
+}
+
+// findFencedBlocks returns the closed code blocks in lines
+func findFencedBlocks(lines []string) []fencedBlock {
+	var blocks []fencedBlock
+	open := -1
+	fence := ""
+	lang := ""
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if open < 0 {
+			if isOpeningFence(line) {
+				open = i
+				fence = trimmed[:3]
+				lang = strings.ToLower(strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])))
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			blocks = append(blocks, fencedBlock{Lang: lang, Start: open, End: i})
+			open = -1
+		}
+	}
+
+	return blocks
+}
+
+// pickBlock chooses the block most likely to hold the file: the longest one
+// tagged with the file's language, or the longest block overall
+func pickBlock(blocks []fencedBlock, ext string) fencedBlock {
+	var best, bestTagged *fencedBlock
+	for i := range blocks {
+		b := &blocks[i]
+		if best == nil || b.End-b.Start > best.End-best.Start {
+			best = b
+		}
+		for _, lang := range fenceLanguages[ext] {
+			if b.Lang == lang && (bestTagged == nil || b.End-b.Start > bestTagged.End-bestTagged.Start) {
+				bestTagged = b
+			}
+		}
+	}
+
+	if bestTagged != nil {
+		return *bestTagged
+	}
+	return *best
+}
+
+// stripChatter removes leading and trailing prose paragraphs from unfenced code
+func stripChatter(lines []string) []string {
+	// Leading sentences, e.g. "Here is the updated file:", and the opening
+	// fence of a block that was never closed because the output was cut off
+	for len(lines) > 0 {
+		first := strings.TrimSpace(lines[0])
+		if first != "" && !leadingChatter.MatchString(first) && !isOpeningFence(first) {
+			break
+		}
+		lines = lines[1:]
+	}
+
+	// Trailing prose, e.g. "This refactor ...", starts a new unindented
+	// paragraph. Code can look the same ("summary = f()" or "changes:" in
+	// YAML), so the rest of the output must also read as prose.
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i-1]) == "" && trailingChatter.MatchString(lines[i]) && isProse(lines[i:]) {
+			lines = lines[:i]
+			break
+		}
+	}
+
+	return lines
+}
+
+// isProse reports whether lines read as sentences rather than code: they end
+// with sentence punctuation and contain no assignments, keys or calls
+func isProse(lines []string) bool {
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if text == "" || strings.ContainsAny(text, "=:(") {
+		return false
+	}
+	return strings.ContainsAny(text[len(text)-1:], ".!?")
+}
+
+// isOpeningFence reports whether line opens a Markdown code block
+func isOpeningFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// isClosingFence reports whether line is a bare fence that can close a code block
+func isClosingFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= 3 && (strings.Trim(trimmed, "`") == "" || strings.Trim(trimmed, "~") == "")
+}
+
+// joinLines joins lines into file content with a single trailing newline
+func joinLines(lines []string) string {
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractFileContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		response string
+		want     string
+	}{
+		{
+			name:     "bare file",
+			filename: "main.go",
+			response: "package main\n\nfunc main() {}\n",
+			want:     "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:     "fenced file",
+			filename: "main.go",
+			response: "```go\npackage main\n```",
+			want:     "package main\n",
+		},
+		{
+			name:     "leading chatter",
+			filename: "main.py",
+			response: "Here is the updated file:\n\nimport os\n\nprint(os.getcwd())\n",
+			want:     "import os\n\nprint(os.getcwd())\n",
+		},
+		{
+			name:     "leading chatter before a fence",
+			filename: "main.go",
+			response: "Sure! Here you go:\n\n```go\npackage main\n```\n\nThis adds the package clause.\n",
+			want:     "package main\n",
+		},
+		{
+			name:     "fence followed by trailing prose",
+			filename: "main.go",
+			response: "```go\npackage main\n```\n\nI renamed the function.\n",
+			want:     "package main\n",
+		},
+		{
+			name:     "tagged block preferred",
+			filename: "main.go",
+			response: "Here is the file:\n```sh\ngo run . --with --many --flags\ngo test ./...\n```\n```go\npackage main\n```\n",
+			want:     "package main\n",
+		},
+		{
+			name:     "trailing prose",
+			filename: "main.py",
+			response: "x = 1\n\nThis renames the variable.\nNothing else changed.\n",
+			want:     "x = 1\n",
+		},
+		{
+			name:     "trailing paragraph that is code",
+			filename: "main.py",
+			response: "import os\n\nsummary = f()\nprint(summary)\n",
+			want:     "import os\n\nsummary = f()\nprint(summary)\n",
+		},
+		{
+			name:     "YAML key that looks like a heading",
+			filename: "ci.yaml",
+			response: "name: ci\n\nchanges:\n  - a\n",
+			want:     "name: ci\n\nchanges:\n  - a\n",
+		},
+		{
+			name:     "unfenced code containing fences",
+			filename: "gen.py",
+			response: "TEMPLATE = \"\"\"\n```sh\nmake\n```\n\"\"\"\n",
+			want:     "TEMPLATE = \"\"\"\n```sh\nmake\n```\n\"\"\"\n",
+		},
+		{
+			name:     "unfenced Markdown containing fences",
+			filename: "README.md",
+			response: "# Title\n\nIntro text.\n\n```sh\ngo build ./...\n```\n\nMore docs here.\n",
+			want:     "# Title\n\nIntro text.\n\n```sh\ngo build ./...\n```\n\nMore docs here.\n",
+		},
+		{
+			name:     "outer fence around Markdown with inner fences",
+			filename: "README.md",
+			response: "````markdown\n# Title\n\n```sh\ngo build ./...\n```\n\nMore docs.\n````",
+			want:     "# Title\n\n```sh\ngo build ./...\n```\n\nMore docs.\n",
+		},
+		{
+			name:     "Markdown with leading prose kept",
+			filename: "NOTES.md",
+			response: "This is the first line of the notes.\n",
+			want:     "This is the first line of the notes.\n",
+		},
+		{
+			name:     "CRLF line endings",
+			filename: "main.go",
+			response: "```go\r\npackage main\r\n```\r\n",
+			want:     "package main\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractFileContent(tt.response, tt.filename); got != tt.want {
+				t.Errorf("ExtractFileContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishRefactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		response string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "Markdown with a code block",
+			filename: "README.md",
+			response: "# Title\n\nIntro text.\n\n```sh\ngo build ./...\n```\n\nMore docs here.\n",
+			want:     "# Title\n\nIntro text.\n\n```sh\ngo build ./...\n```\n\nMore docs here.\n",
+		},
+		{
+			name:     "Go file",
+			filename: "main.go",
+			response: "Here is the refactored file:\n```go\npackage main\n\nfunc main() {}\n```\n",
+			want:     "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:     "truncated Go file",
+			filename: "main.go",
+			response: "package main\n\nfunc main() {\n",
+			wantErr:  "not a complete Go file",
+		},
+		{
+			name:     "invalid JSON",
+			filename: "data.json",
+			response: "{\"a\": 1,\n",
+			wantErr:  "not valid JSON",
+		},
+		{
+			name:     "empty",
+			filename: "main.py",
+			response: "```python\n```",
+			wantErr:  "empty file",
+		},
+		{
+			name:     "Markdown wrapped in chatter and a fence",
+			filename: "README.md",
+			response: "Here is the README:\n```markdown\n# Title\n```",
+			wantErr:  "code fences",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FinishRefactor(tt.filename, tt.response)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("FinishRefactor() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FinishRefactor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FinishRefactor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON schema understood by the providers that
+// need schemas translated, such as Gemini, and by ValidateJSON. CheckSchema
+// rejects schemas using other assertion keywords.
+type jsonSchema struct {
+	Type        schemaType             `json:"type"`
+	Format      string                 `json:"format"`
+	Description string                 `json:"description"`
+	Enum        []any                  `json:"enum"`
+	Const       any                    `json:"const"`
+	Items       *jsonSchema            `json:"items"`
+	Properties  map[string]*jsonSchema `json:"properties"`
+	Required    []string               `json:"required"`
+	AnyOf       []*jsonSchema          `json:"anyOf"`
+	OneOf       []*jsonSchema          `json:"oneOf"`
+	AllOf       []*jsonSchema          `json:"allOf"`
+	Not         *jsonSchema            `json:"not"`
+	Ref         string                 `json:"$ref"`
+
+	// AdditionalProperties is false, true or a schema for properties not
+	// listed in Properties
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+
+	MinLength        *int     `json:"minLength"`
+	MaxLength        *int     `json:"maxLength"`
+	Pattern          string   `json:"pattern"`
+	Minimum          *float64 `json:"minimum"`
+	Maximum          *float64 `json:"maximum"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum"`
+	MultipleOf       *float64 `json:"multipleOf"`
+	MinItems         *int     `json:"minItems"`
+	MaxItems         *int     `json:"maxItems"`
+	UniqueItems      bool     `json:"uniqueItems"`
+	MinProperties    *int     `json:"minProperties"`
+	MaxProperties    *int     `json:"maxProperties"`
+
+	hasConst bool
+	keywords []string // Every keyword in the schema, including unknown ones
+}
+
+// schemaKeywords are the keywords ValidateJSON enforces, and the annotations
+// that don't affect validation. Any other keyword might, so CheckSchema
+// rejects it rather than let a response pass that shouldn't.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "items": true, "properties": true,
+	"required": true, "additionalProperties": true, "anyOf": true, "oneOf": true,
+	"allOf": true, "not": true, "$ref": true, "minLength": true, "maxLength": true,
+	"pattern": true, "minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "multipleOf": true, "minItems": true, "maxItems": true,
+	"uniqueItems": true, "minProperties": true, "maxProperties": true,
+
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "format": true, "deprecated": true,
+	"readOnly": true, "writeOnly": true,
+}
+
+// UnmarshalJSON records whether "const" is present, since it may be null
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	type plain jsonSchema
+	var decoded plain
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+	*s = jsonSchema(decoded)
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err == nil {
+		_, s.hasConst = keys["const"]
+		s.keywords = sortedNames(keys)
+	}
+	return nil
+}
+
+// schemaType is the "type" of a JSON schema, which may be a single type or a
+// list of types such as ["string", "null"]
+type schemaType []string
+
+// UnmarshalJSON accepts a single type name or a list of them
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaType{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = names
+	return nil
+}
+
+// parseJSONSchema parses a JSON schema
+func parseJSONSchema(data json.RawMessage) (*jsonSchema, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// nonNullType returns the schema's type other than "null", and whether null
+// is allowed. A schema without a type is an object if it has properties.
+func (s *jsonSchema) nonNullType() (string, bool, error) {
+	var types []string
+	nullable := false
+	for _, name := range s.Type {
+		if name == "null" {
+			nullable = true
+			continue
+		}
+		types = append(types, name)
+	}
+
+	switch {
+	case len(types) == 1:
+		return types[0], nullable, nil
+	case len(types) == 0 && s.Properties != nil:
+		return "object", nullable, nil
+	case len(types) == 0:
+		return "", nullable, fmt.Errorf("schema has no type")
+	default:
+		return "", nullable, fmt.Errorf("schemas with several types (%v) are not supported", types)
+	}
+}
+
+// CheckSchema reports whether schema is one that ValidateJSON can enforce
+func CheckSchema(schema json.RawMessage) error {
+	parsed, err := parseJSONSchema(schema)
+	if err != nil {
+		return err
+	}
+	return parsed.check("$")
+}
+
+// check rejects schemas that ValidateJSON can't fully enforce
+func (s *jsonSchema) check(path string) error {
+	if s.Ref != "" {
+		return fmt.Errorf("%s: $ref is not supported; inline the referenced schema", path)
+	}
+	for _, keyword := range s.keywords {
+		if !schemaKeywords[keyword] {
+			return fmt.Errorf("%s: the %s keyword is not supported", path, keyword)
+		}
+	}
+	if s.MultipleOf != nil && *s.MultipleOf <= 0 {
+		return fmt.Errorf("%s: multipleOf must be greater than 0", path)
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", path, err)
+		}
+	}
+	if extra, ok := s.additionalSchema(); ok {
+		if err := extra.check(path + ".*"); err != nil {
+			return err
+		}
+	} else if len(s.AdditionalProperties) > 0 && !isJSONBool(s.AdditionalProperties) {
+		return fmt.Errorf("%s: additionalProperties must be a boolean or a schema", path)
+	}
+	if s.Items != nil {
+		if err := s.Items.check(path + "[]"); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedNames(s.Properties) {
+		if err := s.Properties[name].check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	for _, option := range append(append(append([]*jsonSchema(nil), s.AnyOf...), s.OneOf...), s.AllOf...) {
+		if err := option.check(path); err != nil {
+			return err
+		}
+	}
+	if s.Not != nil {
+		return s.Not.check(path)
+	}
+	return nil
+}
+
+// ValidateJSON checks data against schema and returns the ways it doesn't
+// conform, such as "$.items[2].name: expected string, got number"
+func ValidateJSON(schema json.RawMessage, data []byte) ([]string, error) {
+	parsed, err := parseJSONSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.check("$"); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("not valid JSON: %v", err)}, nil
+	}
+	if decoder.More() {
+		return []string{"more than one JSON value"}, nil
+	}
+	return parsed.validate("$", value), nil
+}
+
+// validate returns the problems with value, found at path
+func (s *jsonSchema) validate(path string, value any) []string {
+	if len(s.Type) > 0 && !s.allowsType(value) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(s.Type, " or "), jsonType(value))}
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, option := range s.Enum {
+			if jsonEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %s is not one of %s", path, compactJSON(value), compactJSON(s.Enum))}
+		}
+	}
+	if s.hasConst && !jsonEqual(s.Const, value) {
+		return []string{fmt.Sprintf("%s: must be %s", path, compactJSON(s.Const))}
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, option := range s.AnyOf {
+			if len(option.validate(path, value)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return []string{fmt.Sprintf("%s: does not match any of the allowed schemas", path)}
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, option := range s.OneOf {
+			if len(option.validate(path, value)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of the oneOf schemas instead of exactly one", path, matched)}
+		}
+	}
+	if s.Not != nil && len(s.Not.validate(path, value)) == 0 {
+		return []string{fmt.Sprintf("%s: matches a schema it must not match", path)}
+	}
+
+	var problems []string
+	for _, option := range s.AllOf {
+		problems = append(problems, option.validate(path, value)...)
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			problems = append(problems, fmt.Sprintf("%s: shorter than the minimum length of %d", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			problems = append(problems, fmt.Sprintf("%s: longer than the maximum length of %d", path, *s.MaxLength))
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(value) {
+			problems = append(problems, fmt.Sprintf("%s: does not match the pattern %s", path, s.Pattern))
+		}
+	case json.Number:
+		number, _ := value.Float64()
+		if s.Minimum != nil && number < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: less than the minimum of %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: more than the maximum of %v", path, *s.Maximum))
+		}
+		if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+			problems = append(problems, fmt.Sprintf("%s: not more than %v", path, *s.ExclusiveMinimum))
+		}
+		if s.ExclusiveMaximum != nil && number >= *s.ExclusiveMaximum {
+			problems = append(problems, fmt.Sprintf("%s: not less than %v", path, *s.ExclusiveMaximum))
+		}
+		if s.MultipleOf != nil {
+			if quotient := number / *s.MultipleOf; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+				problems = append(problems, fmt.Sprintf("%s: not a multiple of %v", path, *s.MultipleOf))
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			problems = append(problems, fmt.Sprintf("%s: fewer than %d items", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			problems = append(problems, fmt.Sprintf("%s: more than %d items", path, *s.MaxItems))
+		}
+		if s.UniqueItems {
+		duplicates:
+			for i := range value {
+				for j := 0; j < i; j++ {
+					if jsonEqual(value[i], value[j]) {
+						problems = append(problems, fmt.Sprintf("%s: items %d and %d are the same", path, j, i))
+						break duplicates
+					}
+				}
+			}
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				problems = append(problems, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	case map[string]any:
+		if s.MinProperties != nil && len(value) < *s.MinProperties {
+			problems = append(problems, fmt.Sprintf("%s: fewer than %d properties", path, *s.MinProperties))
+		}
+		if s.MaxProperties != nil && len(value) > *s.MaxProperties {
+			problems = append(problems, fmt.Sprintf("%s: more than %d properties", path, *s.MaxProperties))
+		}
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		extra, hasExtraSchema := s.additionalSchema()
+		for _, name := range sortedNames(value) {
+			propertyPath := path + "." + name
+			if property, ok := s.Properties[name]; ok {
+				problems = append(problems, property.validate(propertyPath, value[name])...)
+			} else if hasExtraSchema {
+				problems = append(problems, extra.validate(propertyPath, value[name])...)
+			} else if string(s.AdditionalProperties) == "false" {
+				problems = append(problems, fmt.Sprintf("%s: unexpected property", propertyPath))
+			}
+		}
+	}
+	return problems
+}
+
+// allowsType reports whether value has one of the schema's types
+func (s *jsonSchema) allowsType(value any) bool {
+	actual := jsonType(value)
+	for _, name := range s.Type {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// additionalSchema returns the schema for additional properties, if one is
+// given rather than a boolean
+func (s *jsonSchema) additionalSchema() (*jsonSchema, bool) {
+	if len(s.AdditionalProperties) == 0 || isJSONBool(s.AdditionalProperties) {
+		return nil, false
+	}
+	var extra jsonSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &extra); err != nil {
+		return nil, false
+	}
+	return &extra, true
+}
+
+// jsonType returns the JSON schema type of a decoded value. Numbers without
+// a fractional part are integers.
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if number, err := value.Float64(); err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// jsonEqual reports whether two decoded JSON values are equal, comparing
+// numbers by value
+func jsonEqual(a any, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// compactJSON returns value as JSON for error messages
+func compactJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// isJSONBool reports whether data is true or false
+func isJSONBool(data json.RawMessage) bool {
+	trimmed := string(bytes.TrimSpace(data))
+	return trimmed == "true" || trimmed == "false"
+}
+
+// sortedNames returns the keys of m in order
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []string
+	}{
+		{
+			name:   "matching object",
+			schema: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name"]}`,
+			data:   `{"name":"Ada","age":36}`,
+		},
+		{
+			name:   "integer written with a fraction of zero",
+			schema: `{"type":"integer"}`,
+			data:   `3.0`,
+		},
+		{
+			name:   "wrong type",
+			schema: `{"type":"object","properties":{"age":{"type":"integer"}}}`,
+			data:   `{"age":3.5}`,
+			want:   []string{"$.age: expected integer, got number"},
+		},
+		{
+			name:   "nullable type",
+			schema: `{"type":["string","null"]}`,
+			data:   `null`,
+		},
+		{
+			name:   "missing required and unexpected property",
+			schema: `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"],"additionalProperties":false}`,
+			data:   `{"extra":1}`,
+			want:   []string{`$: missing required property "name"`, "$.extra: unexpected property"},
+		},
+		{
+			name:   "additional properties schema",
+			schema: `{"type":"object","additionalProperties":{"type":"number"}}`,
+			data:   `{"a":1,"b":"x"}`,
+			want:   []string{"$.b: expected number, got string"},
+		},
+		{
+			name:   "array items and bounds",
+			schema: `{"type":"array","items":{"enum":["a","b"]},"maxItems":2,"uniqueItems":true}`,
+			data:   `["a","c","a"]`,
+			want:   []string{"$: more than 2 items", "$: items 0 and 2 are the same", `$[1]: "c" is not one of ["a","b"]`},
+		},
+		{
+			name:   "const null",
+			schema: `{"const":null}`,
+			data:   `1`,
+			want:   []string{"$: must be null"},
+		},
+		{
+			name:   "string limits",
+			schema: `{"type":"string","minLength":2,"maxLength":3,"pattern":"^[a-z]+$"}`,
+			data:   `"A"`,
+			want:   []string{"$: shorter than the minimum length of 2", "$: does not match the pattern ^[a-z]+$"},
+		},
+		{
+			name:   "number limits",
+			schema: `{"type":"number","minimum":0,"exclusiveMaximum":10,"multipleOf":0.5}`,
+			data:   `10.25`,
+			want:   []string{"$: not less than 10", "$: not a multiple of 0.5"},
+		},
+		{
+			name:   "exclusive minimum",
+			schema: `{"type":"object","properties":{"b":{"exclusiveMinimum":5}}}`,
+			data:   `{"b":1}`,
+			want:   []string{"$.b: not more than 5"},
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf":[{"type":"string"},{"type":"number"}]}`,
+			data:   `true`,
+			want:   []string{"$: does not match any of the allowed schemas"},
+		},
+		{
+			name:   "oneOf",
+			schema: `{"type":"object","properties":{"a":{"oneOf":[{"type":"string"}]}}}`,
+			data:   `{"a":3}`,
+			want:   []string{"$.a: matches 0 of the oneOf schemas instead of exactly one"},
+		},
+		{
+			name:   "oneOf matching several",
+			schema: `{"oneOf":[{"type":"number"},{"type":"integer"}]}`,
+			data:   `1`,
+			want:   []string{"$: matches 2 of the oneOf schemas instead of exactly one"},
+		},
+		{
+			name:   "allOf",
+			schema: `{"allOf":[{"type":"number"},{"minimum":5}]}`,
+			data:   `3`,
+			want:   []string{"$: less than the minimum of 5"},
+		},
+		{
+			name:   "not",
+			schema: `{"not":{"type":"string"}}`,
+			data:   `"x"`,
+			want:   []string{"$: matches a schema it must not match"},
+		},
+		{
+			name:   "property counts",
+			schema: `{"type":"object","minProperties":2}`,
+			data:   `{"a":1}`,
+			want:   []string{"$: fewer than 2 properties"},
+		},
+		{
+			name:   "invalid JSON",
+			schema: `{"type":"object"}`,
+			data:   `{"a":`,
+			want:   []string{"not valid JSON: unexpected EOF"},
+		},
+		{
+			name:   "several values",
+			schema: `{"type":"object"}`,
+			data:   `{} {}`,
+			want:   []string{"more than one JSON value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateJSON(json.RawMessage(tt.schema), []byte(tt.data))
+			if err != nil {
+				t.Fatalf("ValidateJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:   "annotations",
+			schema: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"Person","type":"object","properties":{"name":{"type":"string","description":"Full name","examples":["Ada"]}}}`,
+		},
+		{
+			name:    "ref",
+			schema:  `{"type":"object","properties":{"a":{"$ref":"#/$defs/a"}}}`,
+			wantErr: "$.a: $ref is not supported",
+		},
+		{
+			name:    "unsupported keyword",
+			schema:  `{"type":"object","patternProperties":{"^x":{"type":"string"}}}`,
+			wantErr: "$: the patternProperties keyword is not supported",
+		},
+		{
+			name:    "unsupported keyword in items",
+			schema:  `{"type":"array","items":{"type":"array","prefixItems":[{"type":"string"}]}}`,
+			wantErr: "$[]: the prefixItems keyword is not supported",
+		},
+		{
+			name:    "unsupported keyword in oneOf",
+			schema:  `{"oneOf":[{"if":{"type":"string"}}]}`,
+			wantErr: "$: the if keyword is not supported",
+		},
+		{
+			name:    "invalid pattern",
+			schema:  `{"type":"string","pattern":"("}`,
+			wantErr: "$: invalid pattern",
+		},
+		{
+			name:    "invalid multipleOf",
+			schema:  `{"type":"number","multipleOf":0}`,
+			wantErr: "$: multipleOf must be greater than 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSchema(json.RawMessage(tt.schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckSchema() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Review severity levels, from least to most serious
+var severityLevels = []string{"none", "low", "medium", "high", "critical"}
+
+var severityLine = regexp.MustCompile(`(?im)^[\s*_#>-]*severity[\s*_]*:[\s*_]*(none|low|medium|high|critical)\b`)
+
+// SeverityRank returns the position of level in the severity scale
+func SeverityRank(level string) (int, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	for i, l := range severityLevels {
+		if l == level {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q: use one of %s", level, strings.Join(severityLevels, ", "))
+}
+
+// ThresholdRank returns the rank of a severity used as a --fail-on threshold.
+// "none" is refused, since every review would reach it.
+func ThresholdRank(level string) (int, error) {
+	rank, err := SeverityRank(level)
+	if err != nil {
+		return 0, err
+	}
+	if rank == 0 {
+		return 0, fmt.Errorf("%q can't be a threshold: use one of %s", level, strings.Join(severityLevels[1:], ", "))
+	}
+	return rank, nil
+}
+
+// ParseReviewSeverity returns the overall severity reported at the end of a
+// review, or false if the review does not contain a severity line
+func ParseReviewSeverity(review string) (string, bool) {
+	matches := severityLine.FindAllStringSubmatch(review, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	return strings.ToLower(matches[len(matches)-1][1]), true
+}
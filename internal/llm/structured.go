@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const structuredSystemPrompt = `You answer with data in JSON format.
+Respond with a single JSON value and nothing else: no explanation and no markdown code fences.`
+
+// ErrSchemaMismatch is returned by CompleteStructured when the model's
+// responses don't match the schema
+var ErrSchemaMismatch = errors.New("the model's response did not match the schema")
+
+// jsonSystemPrompt adds the instruction to answer with JSON matching schema
+// to systemPrompt
+func jsonSystemPrompt(systemPrompt string, schema json.RawMessage) string {
+	return fmt.Sprintf("%s\n\nThe JSON must match this JSON schema:\n%s", systemPrompt, schema)
+}
+
+// CompleteStructured asks the model for a response to prompt as JSON matching
+// schema and validates it. A response that doesn't match is sent back with
+// the problems found, up to attempts times in all. It returns the JSON
+// without any surrounding text.
+func CompleteStructured(ctx context.Context, client Client, prompt string, schema json.RawMessage, model string, attempts int) (json.RawMessage, error) {
+	if err := CheckSchema(schema); err != nil {
+		return nil, err
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	request := prompt
+	var problems []string
+	for attempt := 1; attempt <= attempts; attempt++ {
+		response, err := client.CompleteJSON(ctx, structuredSystemPrompt, request, schema, model)
+		if err != nil && !errors.Is(err, ErrOutputTruncated) {
+			return nil, err
+		}
+
+		data := extractJSON(response)
+		if errors.Is(err, ErrOutputTruncated) {
+			problems = []string{"the response was cut off at the output token limit; give a shorter answer"}
+		} else if problems, err = ValidateJSON(schema, data); err != nil {
+			return nil, err
+		}
+		if len(problems) == 0 {
+			return json.RawMessage(data), nil
+		}
+
+		request = fmt.Sprintf("%s\n\nYour previous response was:\n%s\n\nIt does not match the schema:\n- %s\n\nRespond again with only JSON that matches the schema.",
+			prompt, response, strings.Join(problems, "\n- "))
+	}
+	return nil, fmt.Errorf("%w after %d attempts:\n  %s", ErrSchemaMismatch, attempts, strings.Join(problems, "\n  "))
+}
+
+// extractJSON returns the JSON in a response, removing markdown code fences
+// and any text around the outermost object or array
+func extractJSON(response string) []byte {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			// Drop the language, as in ```json
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if json.Valid([]byte(text)) {
+		return []byte(text)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return []byte(text[start : end+1])
+	}
+	return bytes.TrimSpace([]byte(text))
+}
@@ -0,0 +1,29 @@
+package llm
+
+import "testing"
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{name: "bare object", response: `{"a":1}`, want: `{"a":1}`},
+		{name: "surrounding whitespace", response: "\n  [1, 2]\n", want: `[1, 2]`},
+		{name: "fenced", response: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
+		{name: "fenced without language", response: "```\n[true]\n```", want: `[true]`},
+		{name: "prose around object", response: "Here you go: {\"a\":[1]} Hope that helps!", want: `{"a":[1]}`},
+		{name: "prose around array", response: "Result:\n[{\"a\":1}]\nDone.", want: `[{"a":1}]`},
+		{name: "scalar", response: `"text"`, want: `"text"`},
+		{name: "not JSON", response: "  I can't answer that.  ", want: `I can't answer that.`},
+		{name: "unbalanced", response: `{"a": [1, 2}`, want: `{"a": [1, 2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(extractJSON(tt.response)); got != tt.want {
+				t.Errorf("extractJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrToolsNotSupported is returned by Chat when tools are given to a provider
+// that can't call them
+var ErrToolsNotSupported = errors.New("this provider does not support tool calling")
+
+// Roles of the messages in a chat
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // The result of a tool call
+)
+
+// Tool is a local function the model may ask to call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object
+	Parameters json.RawMessage
+}
+
+// schema returns the JSON schema of the tool's arguments, which is an object
+// with no properties if none was given
+func (t Tool) schema() json.RawMessage {
+	if len(t.Parameters) == 0 {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return t.Parameters
+}
+
+// ToolCall is a request from the model to call a tool
+type ToolCall struct {
+	ID        string // Identifies the call, so its result can be matched to it
+	Name      string
+	Arguments json.RawMessage // JSON object of arguments
+}
+
+// Message is one message of a chat
+type Message struct {
+	Role    string
+	Content string
+	// ToolCalls are the calls requested in an assistant message
+	ToolCalls []ToolCall
+	// ToolCallID and Name identify the call a RoleTool message answers
+	ToolCallID string
+	Name       string
+}
+
+// newToolCall returns a tool call from the model, checking that its arguments
+// are a JSON object. Empty arguments are taken as no arguments.
+func newToolCall(id string, name string, arguments string) (ToolCall, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return ToolCall{}, fmt.Errorf("the model called %s with invalid arguments: %w", name, err)
+	}
+	return ToolCall{ID: id, Name: name, Arguments: json.RawMessage(arguments)}, nil
+}
+
+// ToolResult returns the message answering call with content
+func ToolResult(call ToolCall, content string) Message {
+	return Message{Role: RoleTool, Content: content, ToolCallID: call.ID, Name: call.Name}
+}
+
+// ChatRequest is a conversation sent to Chat
+type ChatRequest struct {
+	Messages []Message
+	Tools    []Tool
+	Model    string // Defaults to the client's model
+}
+
+// StreamEvent is reported while a chat response streams in. Exactly one of
+// its fields is set.
+type StreamEvent struct {
+	Text     string    // Text generated since the last event
+	ToolCall *ToolCall // A complete tool call
+}
+
+// emit reports event to onEvent if it is set
+func emit(onEvent func(StreamEvent), event StreamEvent) {
+	if onEvent != nil {
+		onEvent(event)
+	}
+}
+
+// validateChatRequest checks that a conversation is well formed before it is
+// translated for a provider
+func validateChatRequest(req ChatRequest) error {
+	if len(req.Messages) == 0 {
+		return fmt.Errorf("chat has no messages")
+	}
+
+	names := make(map[string]bool)
+	for _, tool := range req.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tool without a name")
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("tool %s is defined more than once", tool.Name)
+		}
+		names[tool.Name] = true
+		if len(tool.Parameters) > 0 && !json.Valid(tool.Parameters) {
+			return fmt.Errorf("parameters of tool %s are not valid JSON", tool.Name)
+		}
+	}
+
+	for _, message := range req.Messages {
+		switch message.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		case RoleTool:
+			if message.ToolCallID == "" {
+				return fmt.Errorf("tool result without a tool call ID")
+			}
+		default:
+			return fmt.Errorf("unknown message role %q", message.Role)
+		}
+	}
+	return nil
+}
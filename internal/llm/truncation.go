@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxContinuations limits how many times ContinueRefactor asks the model to resume
+const maxContinuations = 3
+
+// minShrinkLines is the smallest original file checked for suspicious shrinkage
+const minShrinkLines = 40
+
+// maxShrinkRatio is the fraction of lines an edit may drop before it is flagged
+const maxShrinkRatio = 0.5
+
+// elisionPlaceholder matches comments models use in place of code they left out,
+// such as "// ... rest of file unchanged" or "# ... existing code ..."
+var elisionPlaceholder = regexp.MustCompile(`(?i)^\s*(//|#|--|/\*|\*|<!--|;)\s*((\.\.\.|…).*|[(\[]?((rest|remainder) of (the )?(file|code|class|functions?|methods|implementation|struct|module)|(remaining|existing|previous|other|original|unchanged) (code|methods|functions|implementation|fields|imports|cases|tests))\b.*)$`)
+
+// braceExtensions are file types where unbalanced braces indicate a cut-off file
+var braceExtensions = map[string]bool{
+	".go": true, ".c": true, ".h": true, ".cpp": true, ".java": true, ".js": true, ".jsx": true,
+	".ts": true, ".tsx": true, ".rs": true, ".cs": true, ".kt": true, ".swift": true, ".php": true,
+	".scala": true, ".json": true, ".css": true,
+}
+
+// ContinueRefactor asks the model to finish a refactor whose output was cut
+// off at the token limit, appending continuations until the file is complete.
+// It returns the cleaned-up file like RefactorFile.
+func ContinueRefactor(ctx context.Context, client Client, filename string, content string, instructions string, partial string, model string) (string, error) {
+	output := partial
+	for i := 0; i < maxContinuations; i++ {
+		prompt := fmt.Sprintf(`%s
+
+Your previous response was cut off because it reached the output limit. It ended with:
+
+%s
+
+Continue the file from exactly where that output stopped. Do not repeat anything already written and do not add code fences or commentary.`,
+			buildRefactorPrompt(filename, content, instructions), tail(output, 40))
+
+		continuation, err := client.Complete(ctx, refactorSystemPrompt, prompt, model)
+		output = joinContinuation(output, continuation)
+		if err == nil {
+			return FinishRefactor(filename, output)
+		}
+		if !errors.Is(err, ErrOutputTruncated) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("output still truncated after %d continuations", maxContinuations)
+}
+
+// LostContentChecks selects the checks DetectLostContent treats as problems
+type LostContentChecks struct {
+	Shrink bool // Flag files that lost more than half their lines
+	// StrictBrackets makes unbalanced brackets in files other than Go a
+	// problem. Otherwise they are only a warning, since brackets inside
+	// strings and comments are counted too.
+	StrictBrackets bool
+}
+
+// DetectLostContent compares an edited file with the original and describes
+// signs that content was lost: elision placeholders, a file that stops mid-way,
+// or suspicious shrinkage. Problems should stop the edit; warnings are signs
+// too uncertain to, and should be shown to the user.
+func DetectLostContent(filename string, original string, edited string, checks LostContentChecks) (problems []string, warnings []string) {
+	originalLines := make(map[string]bool)
+	for _, line := range strings.Split(original, "\n") {
+		originalLines[strings.TrimSpace(line)] = true
+	}
+	for i, line := range strings.Split(edited, "\n") {
+		if elisionPlaceholder.MatchString(line) && !originalLines[strings.TrimSpace(line)] {
+			problems = append(problems, fmt.Sprintf("line %d looks like a placeholder for omitted code: %s", i+1, strings.TrimSpace(line)))
+		}
+	}
+
+	const unbalanced = "brackets are unbalanced, so the file may have been cut off"
+	switch ext := strings.ToLower(filepath.Ext(filename)); {
+	case ext == ".go":
+		if goBraceBalance(edited) != goBraceBalance(original) {
+			problems = append(problems, unbalanced)
+		}
+	case braceExtensions[ext]:
+		if braceBalance(edited) != braceBalance(original) {
+			if checks.StrictBrackets {
+				problems = append(problems, unbalanced)
+			} else {
+				warnings = append(warnings, unbalanced)
+			}
+		}
+	}
+
+	if checks.Shrink {
+		before, after := countNonBlank(original), countNonBlank(edited)
+		if before >= minShrinkLines && float64(after) < float64(before)*maxShrinkRatio {
+			problems = append(problems, fmt.Sprintf("file shrank from %d to %d lines", before, after))
+		}
+	}
+
+	return problems, warnings
+}
+
+// joinContinuation appends a continuation to partial output, dropping any
+// lines the model repeated from the end of the partial output
+func joinContinuation(partial string, continuation string) string {
+	continuation = strings.Trim(stripMarkdownFence(continuation), "\n")
+	partialLines := strings.Split(partial, "\n")
+	contLines := strings.Split(continuation, "\n")
+
+	// Find the longest suffix of partial that is a prefix of the continuation
+	for n := min(len(partialLines), len(contLines), 20); n > 0; n-- {
+		if strings.Join(partialLines[len(partialLines)-n:], "\n") == strings.Join(contLines[:n], "\n") {
+			contLines = contLines[n:]
+			break
+		}
+	}
+
+	// If the cut fell mid-line, the model resumes on the same line
+	return partial + strings.Join(contLines, "\n")
+}
+
+// tail returns the last n lines of text
+func tail(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= n {
+		return text
+	}
+	return strings.Join(lines[len(lines)-n:], "\n")
+}
+
+// braceBalance returns the difference between opening and closing brackets
+func braceBalance(text string) int {
+	balance := 0
+	for _, r := range text {
+		switch r {
+		case '{', '(', '[':
+			balance++
+		case '}', ')', ']':
+			balance--
+		}
+	}
+	return balance
+}
+
+// goBraceBalance is braceBalance for Go source, ignoring brackets inside
+// strings, runes and comments
+func goBraceBalance(text string) int {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(text))
+	var s scanner.Scanner
+	// Errors such as an unterminated string are left to the parser
+	s.Init(file, []byte(text), nil, 0)
+
+	balance := 0
+	for {
+		_, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF:
+			return balance
+		case token.LBRACE, token.LPAREN, token.LBRACK:
+			balance++
+		case token.RBRACE, token.RPAREN, token.RBRACK:
+			balance--
+		}
+	}
+}
+
+// countNonBlank returns the number of non-blank lines in text
+func countNonBlank(text string) int {
+	count := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,304 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EricBriscoe/llm-tool/internal/git"
+)
+
+// DefaultTimeout bounds how long a verification command may run
+const DefaultTimeout = 10 * time.Minute
+
+// Result is the outcome of running a check against staged files
+type Result struct {
+	Command string
+	Passed  bool
+	Output  string
+}
+
+// FormatGo parses Go source and returns it formatted with gofmt. Syntax
+// errors are returned with line numbers relative to filename.
+func FormatGo(filename string, content string) (string, error) {
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, filename, content, parser.AllErrors|parser.ParseComments); err != nil {
+		return "", fmt.Errorf("syntax error: %w", err)
+	}
+
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("gofmt failed: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// TypeCheck runs "go vet" on each package containing one of the given Go
+// files, with the staged content substituted for the files on disk through
+// an overlay. files maps destination paths to staged file paths, or to "" for
+// deleted files. The result for each package directory is returned.
+func TypeCheck(ctx context.Context, files map[string]string) (map[string]Result, error) {
+	overlay := struct {
+		Replace map[string]string
+	}{Replace: make(map[string]string)}
+
+	dirs := make(map[string]bool)
+	for dest, staged := range files {
+		if filepath.Ext(dest) != ".go" {
+			continue
+		}
+		abs, err := filepath.Abs(dest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", dest, err)
+		}
+		overlay.Replace[abs] = staged
+		dirs[filepath.Dir(abs)] = true
+	}
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	overlayFile, err := os.CreateTemp("", "llm-tool-overlay-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create overlay file: %w", err)
+	}
+	defer os.Remove(overlayFile.Name())
+
+	if err := json.NewEncoder(overlayFile).Encode(overlay); err != nil {
+		overlayFile.Close()
+		return nil, fmt.Errorf("failed to write overlay file: %w", err)
+	}
+	overlayFile.Close()
+
+	sortedDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Strings(sortedDirs)
+
+	results := make(map[string]Result)
+	for _, dir := range sortedDirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// go vet needs the directory to exist; new packages are only syntax-checked
+			continue
+		}
+
+		output, err := run(ctx, dir, DefaultTimeout, "go", "vet", "-overlay="+overlayFile.Name(), ".")
+		results[dir] = Result{
+			Command: "go vet",
+			Passed:  err == nil,
+			Output:  output,
+		}
+	}
+	return results, nil
+}
+
+// RunInCopy copies the files under root that git doesn't ignore into a temporary directory, writes the
+// staged files over it, removes the deleted ones, and runs command there with
+// sh. files maps destination paths to staged content; paths outside root are
+// ignored. The command runs in the copy of workDir, which must be inside root.
+func RunInCopy(ctx context.Context, root string, workDir string, files map[string]string, deleted []string, command string, timeout time.Duration) (Result, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "llm-tool-verify-*")
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Copy only what git doesn't ignore, leaving out dependencies and build
+	// output; outside a repository, copy everything
+	if visible, err := git.VisibleFiles(root); err == nil {
+		err = copyFiles(root, visible, tmpDir)
+	} else {
+		err = copyTree(root, tmpDir)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to copy %s: %w", root, err)
+	}
+
+	for dest, content := range files {
+		abs, err := filepath.Abs(dest)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to resolve %s: %w", dest, err)
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		target := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return Result{}, fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return Result{}, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+
+	for _, dest := range deleted {
+		abs, err := filepath.Abs(dest)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to resolve %s: %w", dest, err)
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(tmpDir, rel)); err != nil && !os.IsNotExist(err) {
+			return Result{}, fmt.Errorf("failed to remove %s: %w", rel, err)
+		}
+	}
+
+	dir := tmpDir
+	if workDir != "" {
+		absWork, err := filepath.Abs(workDir)
+		if err == nil {
+			if rel, err := filepath.Rel(root, absWork); err == nil && !strings.HasPrefix(rel, "..") {
+				dir = filepath.Join(tmpDir, rel)
+			}
+		}
+	}
+
+	output, err := run(ctx, dir, timeout, "sh", "-c", command)
+	return Result{Command: command, Passed: err == nil, Output: output}, nil
+}
+
+// run executes a command in dir with a timeout and returns its combined output
+func run(ctx context.Context, dir string, timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out.String(), fmt.Errorf("timed out after %s", timeout)
+	}
+	return out.String(), err
+}
+
+// copyTree copies the regular files and directories under src into dst,
+// skipping the .git directory
+func copyTree(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir() && info.Name() == ".git":
+			return filepath.SkipDir
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFiles copies the given files under src into dst, keeping their paths
+// relative to src. Files outside src or missing from disk are skipped, and
+// directories, such as submodules, are copied whole.
+func copyFiles(src string, files []string, dst string) error {
+	// git reports paths with symlinks resolved
+	realSrc, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		rel, err := filepath.Rel(realSrc, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			err = copyTree(path, target)
+		case info.Mode()&os.ModeSymlink != 0:
+			var link string
+			if link, err = os.Readlink(path); err == nil {
+				err = os.Symlink(link, target)
+			}
+		case info.Mode().IsRegular():
+			err = copyFile(path, target, info.Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a single file, preserving its permission bits
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Summarize returns the last maxLines lines of output, for display next to a diff
+func Summarize(output string, maxLines int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("... (%d lines omitted)\n%s", len(lines)-maxLines, strings.Join(lines[len(lines)-maxLines:], "\n"))
+}